@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math/bits"
+	"time"
+)
+
+const (
+	histogramSubBuckets = 16
+	histogramBuckets    = 64 * histogramSubBuckets
+)
+
+// histogram counts latencies in log-linear buckets: each power of two is split into 16 sub-buckets,
+// so percentiles are calculated with relative error less than 1/16.
+type histogram struct {
+	counts [histogramBuckets]int64
+	total  int64
+}
+
+func (h *histogram) record(d time.Duration) {
+	h.counts[bucketIndex(uint64(d))]++
+	h.total++
+}
+
+func (h *histogram) merge(other *histogram) {
+	for i := range h.counts {
+		h.counts[i] += other.counts[i]
+	}
+	h.total += other.total
+}
+
+// percentile returns lower bound of bucket, containing p-th percentile, p is in [0, 100] range.
+func (h *histogram) percentile(p float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	rank := int64(p / 100 * float64(h.total))
+	if rank >= h.total {
+		rank = h.total - 1
+	}
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen > rank {
+			return time.Duration(bucketValue(i))
+		}
+	}
+	return time.Duration(bucketValue(histogramBuckets - 1))
+}
+
+func bucketIndex(v uint64) int {
+	if v < 2*histogramSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - 5
+	return (shift+1)*histogramSubBuckets + int(v>>shift) - histogramSubBuckets
+}
+
+func bucketValue(i int) uint64 {
+	if i < 2*histogramSubBuckets {
+		return uint64(i)
+	}
+	shift := i/histogramSubBuckets - 1
+	return uint64(i%histogramSubBuckets+histogramSubBuckets) << shift
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+func TestBucketIndex(t *testing.T) {
+	cases := []struct {
+		value uint64
+		index int
+		lower uint64
+	}{
+		{value: 0, index: 0, lower: 0},
+		{value: 31, index: 31, lower: 31},
+		{value: 32, index: 32, lower: 32},
+		{value: 33, index: 32, lower: 32},
+		{value: 34, index: 33, lower: 34},
+		{value: 63, index: 47, lower: 62},
+		{value: 64, index: 48, lower: 64},
+		{value: 1000, index: 111, lower: 992},
+		{value: math.MaxUint64, index: 975, lower: 31 << 59},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.index, bucketIndex(c.value), "value %d", c.value)
+		assert.Equal(t, c.lower, bucketValue(c.index), "value %d", c.value)
+	}
+}
+
+func TestBucketRoundTrip(t *testing.T) {
+	last := bucketIndex(math.MaxUint64)
+	for i := 0; i <= last; i++ {
+		lower := bucketValue(i)
+		assert.Equal(t, i, bucketIndex(lower), "lower bound of bucket %d", i)
+		if i < last {
+			upper := bucketValue(i + 1)
+			assert.Equal(t, i, bucketIndex(upper-1), "upper bound of bucket %d", i)
+			assert.LessOrEqual(t, float64(upper-lower), float64(lower)/histogramSubBuckets+1, "bucket %d is too wide", i)
+		}
+	}
+}
+
+func TestHistogram_Percentile(t *testing.T) {
+	var h histogram
+	assert.Zero(t, h.percentile(50), "empty histogram")
+
+	for v := 1; v <= 100; v++ {
+		h.record(time.Duration(v))
+	}
+	cases := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{p: 0, expected: 1},
+		{p: 50, expected: 50}, // 51 is in [50, 52) bucket
+		{p: 90, expected: 88}, // 91 is in [88, 92) bucket
+		{p: 99, expected: 100},
+		{p: 100, expected: 100},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, h.percentile(c.p), "p%v", c.p)
+	}
+
+	var other histogram
+	for i := 0; i < 100; i++ {
+		other.record(time.Second)
+	}
+	h.merge(&other)
+	assert.Equal(t, int64(200), h.total)
+	assert.Equal(t, time.Duration(100), h.percentile(49.5))
+	assert.InEpsilon(t, float64(time.Second), float64(h.percentile(50)), 1.0/histogramSubBuckets)
+}
+
+func TestRecommend(t *testing.T) {
+	cfg := config{keys: 1000, goroutines: []int{8, 32}}
+	generic := func(shards, goroutines int, ops int64) *result {
+		return &result{name: "Generic", shards: shards, goroutines: goroutines, ops: ops, elapsed: time.Second}
+	}
+
+	cases := []struct {
+		name    string
+		results []*result
+		shards  int
+	}{
+		{
+			name: "best throughput",
+			results: []*result{
+				{name: "sync.Map", shards: 1, goroutines: 32, ops: 5000, elapsed: time.Second},
+				generic(16, 8, 5000), // fewer goroutines are ignored
+				generic(256, 32, 1100),
+				generic(16, 32, 1000),
+				generic(64, 32, 1040),
+			},
+			shards: 256,
+		},
+		{
+			name: "the smallest within 5%",
+			results: []*result{
+				generic(256, 32, 1100),
+				generic(64, 32, 1050),
+				generic(16, 32, 1000),
+			},
+			shards: 64,
+		},
+	}
+	for _, c := range cases {
+		shards, shardSize := recommend(cfg, c.results)
+		assert.Equal(t, c.shards, shards, c.name)
+		assert.Equal(t, cfg.keys/c.shards+1, shardSize, c.name)
+	}
+
+	shards, shardSize := recommend(cfg, nil)
+	expectedShards, expectedSize := smap.HeuristicOptimalDistribution(cfg.keys)
+	assert.Equal(t, expectedShards, shards, "heuristic without results")
+	assert.Equal(t, expectedSize, shardSize)
+}
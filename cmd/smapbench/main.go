@@ -0,0 +1,248 @@
+// Command smapbench runs configurable workload against smap.Generic with several shards counts,
+// sync.Map and map with single RWMutex, prints throughput and latency percentiles,
+// and recommends shards count and shard size for smap.NewGeneric.
+//
+// Usage:
+//
+//	smapbench -reads 0.95 -distribution zipf -keys 1000000 -value-size 64 -goroutines 8,32 -duration 2s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+type config struct {
+	readRatio    float64
+	distribution string
+	zipfS        float64
+	keys         int
+	valueSize    int
+	goroutines   []int
+	shards       []int
+	duration     time.Duration
+}
+
+type result struct {
+	name       string
+	shards     int
+	goroutines int
+	ops        int64
+	elapsed    time.Duration
+	latency    histogram
+}
+
+func (r *result) throughput() float64 {
+	return float64(r.ops) / r.elapsed.Seconds()
+}
+
+func main() {
+	cfg, err := parseFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("reads %.0f%%, %s distribution of %d keys, %d bytes values, GOMAXPROCS %d\n\n",
+		cfg.readRatio*100, cfg.distribution, cfg.keys, cfg.valueSize, runtime.GOMAXPROCS(0))
+
+	const rowFormat = "%-10s %8v %11v %12v %10v %10v %10v %10v\n"
+	fmt.Printf(rowFormat, "map", "shards", "goroutines", "ops/s", "p50", "p90", "p99", "p99.9")
+	var results []*result
+	for _, goroutines := range cfg.goroutines {
+		run := func(name string, shards int, m smap.Map[int, []byte]) {
+			r := runScenario(cfg, goroutines, m)
+			r.name, r.shards = name, shards
+			results = append(results, r)
+			fmt.Printf(rowFormat, r.name, r.shards, r.goroutines, int64(r.throughput()),
+				r.latency.percentile(50), r.latency.percentile(90), r.latency.percentile(99), r.latency.percentile(99.9))
+		}
+
+		run("sync.Map", 1, smap.FromSyncMap[int, []byte](&sync.Map{}))
+		run("Locked", 1, smap.NewLocked[int, []byte](cfg.keys))
+		for _, shards := range cfg.shards {
+			run("Generic", shards, smap.NewInteger[int, []byte](shards, cfg.keys/shards+1))
+		}
+	}
+
+	shards, shardSize := recommend(cfg, results)
+	fmt.Printf("\nrecommended: smap.NewGeneric[K, V](%d, %d, shardDetector)\n", shards, shardSize)
+	fmt.Printf("heuristic:   smap.NewGeneric[K, V](smap.HeuristicOptimalDistribution(%d))\n", cfg.keys)
+}
+
+func parseFlags() (config, error) {
+	var (
+		cfg        config
+		goroutines string
+		shards     string
+	)
+	procs := runtime.GOMAXPROCS(0)
+	flag.Float64Var(&cfg.readRatio, "reads", 0.95, "ratio of read operations, [0, 1]")
+	flag.StringVar(&cfg.distribution, "distribution", "uniform", "keys distribution: uniform, zipf or sequential")
+	flag.Float64Var(&cfg.zipfS, "zipf-s", 1.1, "zipf distribution parameter s, should be greater than 1")
+	flag.IntVar(&cfg.keys, "keys", 1<<20, "count of distinct keys")
+	flag.IntVar(&cfg.valueSize, "value-size", 64, "value size in bytes")
+	flag.StringVar(&goroutines, "goroutines", fmt.Sprintf("%d,%d", procs, procs*4), "comma separated goroutines counts")
+	flag.StringVar(&shards, "shards", defaultShards(procs), "comma separated shards counts for smap.Generic")
+	flag.DurationVar(&cfg.duration, "duration", time.Second, "duration of each scenario")
+	flag.Parse()
+
+	var err error
+	if cfg.goroutines, err = parseInts(goroutines); err != nil {
+		return cfg, fmt.Errorf("invalid goroutines: %w", err)
+	}
+	if cfg.shards, err = parseInts(shards); err != nil {
+		return cfg, fmt.Errorf("invalid shards: %w", err)
+	}
+	switch {
+	case cfg.readRatio < 0 || cfg.readRatio > 1:
+		return cfg, fmt.Errorf("reads ratio should be in [0, 1] range, got %v", cfg.readRatio)
+	case cfg.keys <= 0:
+		return cfg, fmt.Errorf("keys count should be positive, got %d", cfg.keys)
+	case cfg.distribution == "zipf" && cfg.zipfS <= 1:
+		return cfg, fmt.Errorf("zipf-s should be greater than 1, got %v", cfg.zipfS)
+	case cfg.distribution != "uniform" && cfg.distribution != "zipf" && cfg.distribution != "sequential":
+		return cfg, fmt.Errorf("unknown distribution %q", cfg.distribution)
+	}
+	return cfg, nil
+}
+
+// defaultShards returns shards counts from GOMAXPROCS to smap.HeuristicOptimalShardsCount and above.
+func defaultShards(procs int) string {
+	counts := []int{procs, procs * 4, procs * procs, smap.HeuristicOptimalShardsCount(), smap.HeuristicOptimalShardsCount() * 4}
+	sort.Ints(counts)
+	values := make([]string, 0, len(counts))
+	for i, count := range counts {
+		if i == 0 || count != counts[i-1] {
+			values = append(values, strconv.Itoa(count))
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func parseInts(s string) ([]int, error) {
+	var values []int
+	for _, part := range strings.Split(s, ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if value <= 0 {
+			return nil, fmt.Errorf("value should be positive, got %d", value)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// runScenario prefills the map, and runs goroutines doing reads and writes for configured duration.
+func runScenario(cfg config, goroutines int, m smap.Map[int, []byte]) *result {
+	for key := 0; key < cfg.keys; key++ {
+		m.Store(key, make([]byte, cfg.valueSize))
+	}
+	runtime.GC()
+
+	var (
+		stop int32
+		wg   sync.WaitGroup
+	)
+	results := make([]result, goroutines)
+	wg.Add(goroutines)
+	start := time.Now()
+	for g := 0; g < goroutines; g++ {
+		go func(g int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(g)))
+			next := keyGenerator(cfg, rnd, g, goroutines)
+			value := make([]byte, cfg.valueSize)
+			r := &results[g]
+			for atomic.LoadInt32(&stop) == 0 {
+				key := next()
+				read := rnd.Float64() < cfg.readRatio
+				begin := time.Now()
+				if read {
+					m.Load(key)
+				} else {
+					m.Store(key, value)
+				}
+				r.latency.record(time.Since(begin))
+				r.ops++
+			}
+		}(g)
+	}
+	time.Sleep(cfg.duration)
+	atomic.StoreInt32(&stop, 1)
+	wg.Wait()
+
+	total := &result{goroutines: goroutines, elapsed: time.Since(start)}
+	for i := range results {
+		total.ops += results[i].ops
+		total.latency.merge(&results[i].latency)
+	}
+	return total
+}
+
+func keyGenerator(cfg config, rnd *rand.Rand, g, goroutines int) func() int {
+	switch cfg.distribution {
+	case "zipf":
+		zipf := rand.NewZipf(rnd, cfg.zipfS, 1, uint64(cfg.keys-1))
+		return func() int {
+			return int(zipf.Uint64())
+		}
+	case "sequential":
+		key := g * cfg.keys / goroutines
+		return func() int {
+			key++
+			if key == cfg.keys {
+				key = 0
+			}
+			return key
+		}
+	default:
+		return func() int {
+			return rnd.Intn(cfg.keys)
+		}
+	}
+}
+
+// recommend returns shards count with the best throughput of smap.Generic on the largest goroutines count.
+// Shards counts with throughput within 5% of the best are considered equal, and the smallest of them is chosen,
+// since each shard costs memory.
+func recommend(cfg config, results []*result) (int, int) {
+	maxGoroutines := 0
+	for _, g := range cfg.goroutines {
+		if g > maxGoroutines {
+			maxGoroutines = g
+		}
+	}
+
+	var candidates []*result
+	best := 0.0
+	for _, r := range results {
+		if r.name == "Generic" && r.goroutines == maxGoroutines {
+			candidates = append(candidates, r)
+			if r.throughput() > best {
+				best = r.throughput()
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].shards < candidates[j].shards
+	})
+	for _, r := range candidates {
+		if r.throughput() >= best*0.95 {
+			return r.shards, cfg.keys/r.shards + 1
+		}
+	}
+	return smap.HeuristicOptimalDistribution(cfg.keys)
+}
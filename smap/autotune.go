@@ -0,0 +1,451 @@
+package smap
+
+import (
+	"math/bits"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+// AutoTuneConfig configures AutoTuned map. Zero fields are replaced with defaults.
+type AutoTuneConfig struct {
+	// InitialShards is shards count on creation, HeuristicOptimalShardsCount by default.
+	InitialShards int
+	// MinShards and MaxShards limit shards count, GOMAXPROCS and 4*HeuristicOptimalShardsCount by default.
+	MinShards int
+	MaxShards int
+
+	// GrowContention is ratio of failed TryLock attempts, above which shards count is doubled, 0.05 by default.
+	GrowContention float64
+	// ShrinkContention is ratio of failed TryLock attempts, below which shards count could be halved,
+	// 0.005 by default. Gap between ShrinkContention and GrowContention prevents flapping.
+	ShrinkContention float64
+	// MaxLoadFactor is average shard length, above which shards count is doubled, 65536 by default.
+	MaxLoadFactor float64
+	// MinLoadFactor is average shard length, below which shards count is halved if contention is low, 16 by default.
+	MinLoadFactor float64
+	// MinSamples is minimal count of lock attempts between tunings, required for contention based decisions.
+	// 1024 by default.
+	MinSamples int64
+	// Patience is count of consecutive tunings with thresholds crossed, required to reshard, 3 by default.
+	Patience int
+
+	// Interval is period of automatic tuning. If zero, tuning is done only by Tune calls.
+	Interval time.Duration
+	// OnDecision is called after each tuning, including ones which keep shards count.
+	OnDecision func(Decision)
+}
+
+// Decision describes result of AutoTuned map tuning. To equals From, if shards count is kept.
+type Decision struct {
+	From       int
+	To         int
+	Contention float64
+	LoadFactor float64
+	Reason     string
+}
+
+// AutoTuned is sharded map, that changes shards count depending on observed lock contention and load factor.
+// Each lock is tried with TryLock first, and failed attempts are counted.
+// Resharding locks all shards, moves entries to the new layout, and blocks all operations while entries are moved.
+type AutoTuned[K comparable, V any] struct {
+	state *autoTunedState[K, V]
+}
+
+type autoTunedState[K comparable, V any] struct {
+	current atomic.Value // *autoTunedLayout[K, V]
+	hash    func(key K) uint64
+	config  AutoTuneConfig
+	opts    []Option
+
+	tuneLock     sync.Mutex
+	growStreak   int
+	shrinkStreak int
+	stop         chan struct{}
+	stopOnce     sync.Once
+}
+
+// autoTunedLayout is map with fixed shards count. When entries are moved to the new layout, moved flag is set
+// under all shard locks, so operation should retry with the current layout, if it's set.
+type autoTunedLayout[K comparable, V any] struct {
+	m         Generic[K, V]
+	moved     bool
+	attempts  []int64
+	contended []int64
+}
+
+var _ Map[int, int] = AutoTuned[int, int]{}
+
+// NewAutoTuned creates self-tuning sharded map. Shard is detected from high bits of key hash,
+// so hash function should mix them well, e.g. shard.XXHash64 for strings.
+// Options are applied to each layout, e.g. WithLock or WithStats.
+// If config.Interval is set, Close should be called to stop tuning goroutine.
+func NewAutoTuned[K comparable, V any](hash func(key K) uint64, config AutoTuneConfig, opts ...Option) AutoTuned[K, V] {
+	config = config.withDefaults()
+	state := &autoTunedState[K, V]{
+		hash:   hash,
+		config: config,
+		opts:   append([]Option(nil), opts...),
+		stop:   make(chan struct{}),
+	}
+	state.current.Store(state.newLayout(config.InitialShards, 0))
+	at := AutoTuned[K, V]{state: state}
+	if config.Interval > 0 {
+		go at.tuneLoop()
+	}
+	return at
+}
+
+func (c AutoTuneConfig) withDefaults() AutoTuneConfig {
+	if c.MinShards <= 0 {
+		c.MinShards = runtime.GOMAXPROCS(0)
+	}
+	if c.MaxShards <= 0 {
+		c.MaxShards = 4 * HeuristicOptimalShardsCount()
+	}
+	if c.MaxShards < c.MinShards {
+		c.MaxShards = c.MinShards
+	}
+	if c.InitialShards <= 0 {
+		c.InitialShards = HeuristicOptimalShardsCount()
+	}
+	c.InitialShards = clampInt(c.InitialShards, c.MinShards, c.MaxShards)
+	if c.GrowContention <= 0 {
+		c.GrowContention = 0.05
+	}
+	if c.ShrinkContention <= 0 {
+		c.ShrinkContention = 0.005
+	}
+	if c.MaxLoadFactor <= 0 {
+		c.MaxLoadFactor = 1 << 16
+	}
+	if c.MinLoadFactor <= 0 {
+		c.MinLoadFactor = 16
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = 1024
+	}
+	if c.Patience <= 0 {
+		c.Patience = 3
+	}
+	return c
+}
+
+// Load returns the value stored in the map for a key.
+// The ok result indicates whether value was found in the map.
+func (at AutoTuned[K, V]) Load(key K) (V, bool) {
+	if !at.mayContain(key) {
+		var zero V
+		return zero, false
+	}
+	l, shardID := at.rlock(key)
+	value, ok := l.m.shards[shardID][key]
+	l.m.locks[shardID].RUnlock()
+	return value, ok
+}
+
+// Store sets the value for a key.
+func (at AutoTuned[K, V]) Store(key K, value V) {
+	l, shardID := at.lock(key)
+	l.m.storing(shardID, key)
+	l.m.shards[shardID][key] = value
+	l.m.locks[shardID].Unlock()
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (at AutoTuned[K, V]) LoadAndDelete(key K) (V, bool) {
+	if !at.mayContain(key) {
+		var zero V
+		return zero, false
+	}
+	l, shardID := at.lock(key)
+	value, ok := l.m.shards[shardID][key]
+	if ok {
+		delete(l.m.shards[shardID], key)
+		l.m.removed(shardID, key)
+		l.m.deleted(shardID, 1)
+	}
+	l.m.locks[shardID].Unlock()
+	return value, ok
+}
+
+// LoadOrCreate returns the existing value for the key if present.
+// Otherwise, it calls generator func, stores and returns the generator's result.
+// Generator will not be called if key present.
+// The loaded result is true if the value was loaded, false if stored.
+func (at AutoTuned[K, V]) LoadOrCreate(key K, generator func() V) (V, bool) {
+	if value, ok := at.Load(key); ok {
+		return value, ok
+	}
+
+	l, shardID := at.lock(key)
+	value, ok := l.m.shards[shardID][key]
+	if !ok {
+		value = generator()
+		l.m.storing(shardID, key)
+		l.m.shards[shardID][key] = value
+	}
+	l.m.locks[shardID].Unlock()
+	return value, ok
+}
+
+// Delete deletes the value for a key.
+func (at AutoTuned[K, V]) Delete(key K) {
+	if !at.mayContain(key) {
+		return
+	}
+	l, shardID := at.lock(key)
+	if _, ok := l.m.shards[shardID][key]; ok {
+		delete(l.m.shards[shardID], key)
+		l.m.removed(shardID, key)
+		l.m.deleted(shardID, 1)
+	}
+	l.m.locks[shardID].Unlock()
+}
+
+// Range calls cb sequentially for each key and value present in the map.
+// If cb returns false, range stops the iteration.
+// Range has the same guarantees as Generic.Range. Shards are ordered by key hash in any layout,
+// so if map is resharded during Range, iteration continues over the new layout from the lowest hash,
+// which was not visited yet.
+func (at AutoTuned[K, V]) Range(cb func(K, V) bool) {
+	var (
+		from uint64 // keys with lower hash are visited
+		keys []K
+	)
+	l := at.layout()
+	for shardID := 0; shardID < len(l.m.shards); shardID++ {
+		l.m.locks[shardID].RLock()
+		if l.moved {
+			l.m.locks[shardID].RUnlock()
+			l = at.layout()
+			shardID = hashShard(from, len(l.m.shards)) - 1
+			continue
+		}
+		keys = keys[:0]
+		partial := from > shardStart(shardID, len(l.m.shards))
+		for key := range l.m.shards[shardID] {
+			if !partial || at.state.hash(key) >= from {
+				keys = append(keys, key)
+			}
+		}
+		l.m.locks[shardID].RUnlock()
+
+		for _, key := range keys {
+			if value, ok := at.Load(key); ok && !cb(key, value) {
+				return
+			}
+		}
+		if shardID+1 < len(l.m.shards) {
+			from = shardStart(shardID+1, len(l.m.shards))
+		}
+	}
+}
+
+// Len returns count of entries in the map.
+func (at AutoTuned[K, V]) Len() int {
+	return at.layout().len()
+}
+
+// ShardsCount returns current shards count.
+func (at AutoTuned[K, V]) ShardsCount() int {
+	return at.layout().m.ShardsCount()
+}
+
+// Tune samples lock contention since previous call and load factor, and reshards map if thresholds are crossed
+// for Patience consecutive calls. Called periodically, if Interval is set in config.
+func (at AutoTuned[K, V]) Tune() {
+	s := at.state
+	s.tuneLock.Lock()
+	defer s.tuneLock.Unlock()
+
+	l := at.layout()
+	var attempts, contended int64
+	for i := range l.attempts {
+		attempts += atomic.SwapInt64(&l.attempts[i], 0)
+		contended += atomic.SwapInt64(&l.contended[i], 0)
+	}
+	shards := l.m.ShardsCount()
+	decision := Decision{
+		From:       shards,
+		To:         shards,
+		LoadFactor: float64(l.len()) / float64(shards),
+	}
+	sampled := attempts >= s.config.MinSamples
+	if sampled {
+		decision.Contention = float64(contended) / float64(attempts)
+	}
+
+	switch {
+	case decision.LoadFactor > s.config.MaxLoadFactor:
+		decision.Reason = "load factor is above maximum"
+		s.growStreak++
+		s.shrinkStreak = 0
+	case sampled && decision.Contention > s.config.GrowContention:
+		decision.Reason = "lock contention is above threshold"
+		s.growStreak++
+		s.shrinkStreak = 0
+	case decision.LoadFactor < s.config.MinLoadFactor && (!sampled || decision.Contention < s.config.ShrinkContention):
+		decision.Reason = "load factor is below minimum with low lock contention"
+		s.shrinkStreak++
+		s.growStreak = 0
+	default:
+		decision.Reason = "load factor and lock contention are within thresholds"
+		s.growStreak, s.shrinkStreak = 0, 0
+	}
+
+	switch {
+	case s.growStreak >= s.config.Patience && shards < s.config.MaxShards:
+		decision.To = clampInt(shards*2, s.config.MinShards, s.config.MaxShards)
+	case s.shrinkStreak >= s.config.Patience && shards > s.config.MinShards:
+		decision.To = clampInt(shards/2, s.config.MinShards, s.config.MaxShards)
+	}
+	if decision.To != decision.From {
+		s.growStreak, s.shrinkStreak = 0, 0
+		at.reshard(l, decision.To)
+	}
+	if s.config.OnDecision != nil {
+		s.config.OnDecision(decision)
+	}
+}
+
+// Close stops automatic tuning goroutine. Map could be used after Close.
+func (at AutoTuned[K, V]) Close() {
+	at.state.stopOnce.Do(func() {
+		close(at.state.stop)
+	})
+}
+
+func (at AutoTuned[K, V]) tuneLoop() {
+	ticker := time.NewTicker(at.state.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			at.Tune()
+		case <-at.state.stop:
+			return
+		}
+	}
+}
+
+// reshard moves entries from old layout to the new one with given shards count, holding all old shard locks.
+func (at AutoTuned[K, V]) reshard(old *autoTunedLayout[K, V], shardsCount int) {
+	for i := range old.m.locks {
+		old.m.locks[i].Lock()
+	}
+	l := at.state.newLayout(shardsCount, old.lenUnblocked()/shardsCount+1)
+	for i := range old.m.shards {
+		for key, value := range old.m.shards[i] {
+			shardID := l.m.shardDetector(key)
+			l.m.storing(shardID, key)
+			l.m.shards[shardID][key] = value
+		}
+	}
+	at.state.current.Store(l)
+	old.moved = true
+	for i := range old.m.locks {
+		old.m.locks[i].Unlock()
+	}
+}
+
+func (at AutoTuned[K, V]) layout() *autoTunedLayout[K, V] {
+	return at.state.current.Load().(*autoTunedLayout[K, V])
+}
+
+// mayContain checks Bloom filter of the current layout, if it's enabled. Moved entries are added
+// to the new layout filter before the layout is published, so false result is reliable.
+func (at AutoTuned[K, V]) mayContain(key K) bool {
+	l := at.layout()
+	return l.m.mayContain(l.m.shardDetector(key), key)
+}
+
+// lock locks shard for key in the current layout, counting contention.
+func (at AutoTuned[K, V]) lock(key K) (*autoTunedLayout[K, V], int) {
+	for {
+		l := at.layout()
+		shardID := l.m.shardDetector(key)
+		atomic.AddInt64(&l.attempts[shardID], 1)
+		if !l.m.locks[shardID].TryLock() {
+			atomic.AddInt64(&l.contended[shardID], 1)
+			l.m.locks[shardID].Lock()
+		}
+		if !l.moved {
+			return l, shardID
+		}
+		l.m.locks[shardID].Unlock()
+	}
+}
+
+// rlock locks for read shard for key in the current layout, counting contention.
+func (at AutoTuned[K, V]) rlock(key K) (*autoTunedLayout[K, V], int) {
+	for {
+		l := at.layout()
+		shardID := l.m.shardDetector(key)
+		atomic.AddInt64(&l.attempts[shardID], 1)
+		if !l.m.locks[shardID].TryRLock() {
+			atomic.AddInt64(&l.contended[shardID], 1)
+			l.m.locks[shardID].RLock()
+		}
+		if !l.moved {
+			return l, shardID
+		}
+		l.m.locks[shardID].RUnlock()
+	}
+}
+
+func (s *autoTunedState[K, V]) newLayout(shardsCount, shardSize int) *autoTunedLayout[K, V] {
+	return &autoTunedLayout[K, V]{
+		m:         NewGeneric[K, V](shardsCount, shardSize, shard.Must(shard.FromHash(shardsCount, s.hash)), s.opts...),
+		attempts:  make([]int64, shardsCount),
+		contended: make([]int64, shardsCount),
+	}
+}
+
+func (l *autoTunedLayout[K, V]) len() int {
+	count := 0
+	for i := range l.m.shards {
+		l.m.locks[i].RLock()
+		count += len(l.m.shards[i])
+		l.m.locks[i].RUnlock()
+	}
+	return count
+}
+
+// lenUnblocked returns count of entries, should be called only under all shard locks.
+func (l *autoTunedLayout[K, V]) lenUnblocked() int {
+	count := 0
+	for i := range l.m.shards {
+		count += len(l.m.shards[i])
+	}
+	return count
+}
+
+// shardStart returns the lowest hash, mapped to the shard by shard.FromHash detector.
+func shardStart(shardID, shardsCount int) uint64 {
+	start, rem := bits.Div64(uint64(shardID), 0, uint64(shardsCount))
+	if rem != 0 {
+		start++
+	}
+	return start
+}
+
+// hashShard returns shard for the hash, the same as shard.FromHash detector.
+func hashShard(hash uint64, shardsCount int) int {
+	hi, _ := bits.Mul64(hash, uint64(shardsCount))
+	return int(hi)
+}
+
+func clampInt(value, min, max int) int {
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
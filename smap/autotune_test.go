@@ -0,0 +1,218 @@
+package smap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+func intHash(key int) uint64 {
+	return uint64(key) * 11400714819323198485
+}
+
+func TestAutoTuned_GrowOnContention(t *testing.T) {
+	var decisions []Decision
+	m := NewAutoTuned[int, int](intHash, AutoTuneConfig{
+		InitialShards: 4,
+		MinShards:     2,
+		MaxShards:     16,
+		MinSamples:    100,
+		OnDecision: func(d Decision) {
+			decisions = append(decisions, d)
+		},
+	})
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+
+	contend := func(rate int64) {
+		l := m.layout()
+		l.attempts[0], l.contended[0] = 1000, rate
+	}
+	contend(100)
+	m.Tune()
+	contend(100)
+	m.Tune()
+	contend(1) // contention dropped, streak is reset
+	m.Tune()
+	assert.Equal(t, 4, m.ShardsCount())
+
+	for i := 0; i < 3; i++ {
+		contend(100)
+		m.Tune()
+	}
+	assert.Equal(t, 8, m.ShardsCount())
+	assert.Len(t, decisions, 6)         // each tuning is reported
+	assert.Equal(t, 4, decisions[0].To) // patience is not exhausted yet
+	assert.Equal(t, "lock contention is above threshold", decisions[0].Reason)
+	assert.Equal(t, Decision{From: 4, To: 4, Contention: 0.001, LoadFactor: 250, Reason: "load factor and lock contention are within thresholds"}, decisions[2])
+	assert.Equal(t, Decision{From: 4, To: 8, Contention: 0.1, LoadFactor: 250, Reason: "lock contention is above threshold"}, decisions[5])
+
+	for i := 0; i < 1000; i++ { // entries are moved to the new layout
+		val, ok := m.Load(i)
+		assert.True(t, ok)
+		assert.Equal(t, i, val)
+	}
+	assert.Equal(t, 1000, m.Len())
+
+	for i := 0; i < 6; i++ {
+		contend(100)
+		m.Tune()
+	}
+	assert.Equal(t, 16, m.ShardsCount()) // limited by MaxShards
+	assert.Len(t, decisions, 12)
+	assert.Equal(t, 16, decisions[11].From)
+	assert.Equal(t, 16, decisions[11].To)
+}
+
+func TestAutoTuned_ShrinkOnLowLoad(t *testing.T) {
+	var decisions []Decision
+	m := NewAutoTuned[int, int](intHash, AutoTuneConfig{
+		InitialShards: 64,
+		MinShards:     16,
+		OnDecision: func(d Decision) {
+			decisions = append(decisions, d)
+		},
+	})
+	m.Store(1, 1)
+	for i := 0; i < 9; i++ {
+		m.Tune()
+	}
+	assert.Equal(t, 16, m.ShardsCount())
+	assert.Len(t, decisions, 9)
+	assert.Equal(t, 64, decisions[2].From)
+	assert.Equal(t, 32, decisions[2].To)
+	assert.Equal(t, "load factor is below minimum with low lock contention", decisions[2].Reason)
+
+	val, ok := m.Load(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+}
+
+func TestAutoTuned_ConcurrentResharding(t *testing.T) {
+	m := NewAutoTuned[int, int](intHash, AutoTuneConfig{
+		InitialShards: 2,
+		MinShards:     1,
+		MaxShards:     64,
+		MinLoadFactor: 0.1,
+		MaxLoadFactor: 1,
+		Patience:      1,
+	})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g * 1000; i < (g+1)*1000; i++ {
+				m.Store(i, i)
+				val, ok := m.Load(i)
+				assert.True(t, ok)
+				assert.Equal(t, i, val)
+			}
+		}(g)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for tuning := true; tuning; {
+		select {
+		case <-done:
+			tuning = false
+		default:
+			m.Tune()
+		}
+	}
+
+	for i := 0; i < 6; i++ {
+		m.Tune()
+	}
+	assert.Equal(t, 4000, m.Len())
+	assert.Equal(t, 64, m.ShardsCount())
+}
+
+func TestAutoTuned_Interval(t *testing.T) {
+	decided := make(chan Decision, 1)
+	m := NewAutoTuned[string, int](shard.XXHash64, AutoTuneConfig{
+		InitialShards: 4,
+		MinShards:     2,
+		Patience:      1,
+		Interval:      1,
+		OnDecision: func(d Decision) {
+			select {
+			case decided <- d:
+			default:
+			}
+		},
+	})
+	defer m.Close()
+	d := <-decided
+	assert.Equal(t, Decision{From: 4, To: 2, Reason: "load factor is below minimum with low lock contention"}, d)
+}
+
+func TestAutoTuned_RangeDuringResharding(t *testing.T) {
+	for _, shards := range []int{3, 16} { // grow and shrink
+		m := NewAutoTuned[int, int](intHash, AutoTuneConfig{
+			InitialShards: 8,
+			MinShards:     3,
+			MaxShards:     16,
+			MinLoadFactor: 1000,
+			MaxLoadFactor: 10,
+			Patience:      1,
+		})
+		if shards < 8 {
+			m.state.config.MaxLoadFactor = 1 << 16
+		}
+		for i := 0; i < 1000; i++ {
+			m.Store(i, i)
+		}
+
+		visited := make(map[int]int, 1000)
+		m.Range(func(k, v int) bool {
+			visited[k]++
+			if len(visited)%100 == 0 {
+				m.Tune()
+			}
+			if len(visited) == 500 {
+				for i := 0; i < 1000; i += 2 {
+					if visited[i] == 0 { // changes after resharding are visible
+						m.Delete(i)
+					}
+				}
+			}
+			return true
+		})
+		assert.Equal(t, shards, m.ShardsCount())
+		assert.Equal(t, m.Len(), len(visited))
+		for k, count := range visited {
+			assert.Equal(t, 1, count, "key %d is visited more than once", k)
+		}
+	}
+}
+
+func TestAutoTuned_Options(t *testing.T) {
+	m := NewAutoTuned[int, int](intHash, AutoTuneConfig{InitialShards: 4, MinShards: 2, MinLoadFactor: 100, Patience: 1},
+		WithLock(LockMutex), WithStats(), WithBloomFilter(intHash, 1024, 0.01))
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	m.Delete(0)
+	m.Tune()
+	assert.Equal(t, 2, m.ShardsCount())
+
+	l := m.layout()
+	assert.IsType(t, &mutexLock{}, l.m.locks[0].ext.custom)
+	assert.NotNil(t, l.m.locks[0].ext.stats)
+	assert.NotNil(t, l.m.blooms)
+	for i := 0; i < 200; i++ { // moved entries are added to Bloom filter of the new layout
+		val, ok := m.Load(i)
+		assert.Equal(t, i > 0 && i < 100, ok)
+		if ok {
+			assert.Equal(t, i, val)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package smap
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// bloomCounterMax is saturated counter value. Saturated counters are never decremented, so filter has no false negatives.
+const bloomCounterMax = 0xff
+
+// WithBloomFilter enables per-shard counting Bloom filter, so lookups of missing keys mostly do not take shard lock.
+// Filter is checked lock-free by Load, LoadAndDelete, LoadOrCreate, Delete and ReadValue, and maintained by all
+// mutating methods. hash should be well-distributed hash of the key, K should match map key type.
+// Filters are sized for expectedItems with given falsePositiveRate, more items increase false positive rate.
+// Each filter counter takes one byte.
+func WithBloomFilter[K comparable](hash func(key K) uint64, expectedItems int, falsePositiveRate float64) Option {
+	return func(o *options) {
+		o.bloomHash = hash
+		o.bloomItems = expectedItems
+		o.bloomFalsePositiveRate = falsePositiveRate
+	}
+}
+
+// bloomFilter is counting Bloom filter with 8-bit counters, packed into words, which are read atomically.
+// Writers should be serialized, so it's modified only under shard write lock.
+type bloomFilter struct {
+	counters []uint32
+	size     uint32 // count of counters
+	hashes   uint32
+}
+
+// newBloomFilters creates filter for each shard. Panics if hash type does not match key type.
+func newBloomFilters[K comparable](shardsCount int, o options) (func(key K) uint64, []bloomFilter) {
+	hash, ok := o.bloomHash.(func(key K) uint64)
+	if !ok {
+		panic("smap: WithBloomFilter hash function does not match map key type")
+	}
+	return hash, newShardBloomFilters(shardsCount, o.bloomItems, o.bloomFalsePositiveRate)
+}
+
+// newShardBloomFilters creates filter for each shard, sized for expectedItems split between shards evenly.
+func newShardBloomFilters(shardsCount, expectedItems int, falsePositiveRate float64) []bloomFilter {
+	items := float64(expectedItems) / float64(shardsCount)
+	if items < 1 {
+		items = 1
+	}
+	rate := falsePositiveRate
+	if rate <= 0 || rate >= 1 {
+		rate = 0.01
+	}
+	size := math.Ceil(-items * math.Log(rate) / (math.Ln2 * math.Ln2))
+	hashes := uint32(math.Round(size / items * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+
+	filters := make([]bloomFilter, shardsCount)
+	words := (uint32(size) + 3) / 4
+	for i := range filters {
+		filters[i] = bloomFilter{
+			counters: make([]uint32, words),
+			size:     words * 4,
+			hashes:   hashes,
+		}
+	}
+	return filters
+}
+
+// mayContain reports whether key with given hash could be present. It's safe to call without lock.
+func (f *bloomFilter) mayContain(hash uint64) bool {
+	h1, h2 := bloomHashes(hash)
+	for i := uint32(0); i < f.hashes; i++ {
+		word, shift := f.position(h1 + i*h2)
+		if (atomic.LoadUint32(&f.counters[word])>>shift)&bloomCounterMax == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *bloomFilter) add(hash uint64) {
+	h1, h2 := bloomHashes(hash)
+	for i := uint32(0); i < f.hashes; i++ {
+		word, shift := f.position(h1 + i*h2)
+		value := atomic.LoadUint32(&f.counters[word])
+		if (value>>shift)&bloomCounterMax != bloomCounterMax {
+			atomic.StoreUint32(&f.counters[word], value+1<<shift)
+		}
+	}
+}
+
+func (f *bloomFilter) remove(hash uint64) {
+	h1, h2 := bloomHashes(hash)
+	for i := uint32(0); i < f.hashes; i++ {
+		word, shift := f.position(h1 + i*h2)
+		value := atomic.LoadUint32(&f.counters[word])
+		if counter := (value >> shift) & bloomCounterMax; counter != 0 && counter != bloomCounterMax {
+			atomic.StoreUint32(&f.counters[word], value-1<<shift)
+		}
+	}
+}
+
+// position returns word index and bit shift of the counter for hash.
+func (f *bloomFilter) position(hash uint32) (uint32, uint32) {
+	counter := uint32(uint64(hash) * uint64(f.size) >> 32)
+	return counter / 4, counter % 4 * 8
+}
+
+// bloomHashes returns two hashes for double hashing. Key hash is mixed first, since shard detector
+// could use the same hash, and keys of one shard share some of its bits.
+func bloomHashes(hash uint64) (uint32, uint32) {
+	hash ^= hash >> 33
+	hash *= 0xff51afd7ed558ccd
+	hash ^= hash >> 33
+	return uint32(hash), uint32(hash>>32) | 1
+}
+
+// mayContain reports whether key could be present in the shard, checking Bloom filter without lock.
+// Always true if filter is disabled.
+func (sm Generic[K, V]) mayContain(shardID int, key K) bool {
+	return sm.blooms == nil || sm.blooms[shardID].mayContain(sm.bloomHash(key))
+}
+
+// storing should be called under shard write lock before value is stored for the key.
+func (sm Generic[K, V]) storing(shardID int, key K) {
+	if sm.blooms == nil {
+		return
+	}
+	if _, ok := sm.shards[shardID][key]; !ok {
+		sm.blooms[shardID].add(sm.bloomHash(key))
+	}
+}
+
+// removed should be called under shard write lock after present key is deleted.
+func (sm Generic[K, V]) removed(shardID int, key K) {
+	if sm.blooms != nil {
+		sm.blooms[shardID].remove(sm.bloomHash(key))
+	}
+}
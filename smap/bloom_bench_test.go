@@ -0,0 +1,48 @@
+package smap_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+const bloomBenchmarkKeys = 1 << 16
+
+func fibonacciHash(key int) uint64 {
+	return uint64(key) * 11400714819323198485
+}
+
+// BenchmarkGeneric_MissHeavy loads keys, 90% of which are missing, with concurrent writes,
+// with and without Bloom filter.
+func BenchmarkGeneric_MissHeavy(b *testing.B) {
+	for _, bloom := range []bool{false, true} {
+		b.Run(fmt.Sprintf("bloom=%t", bloom), func(b *testing.B) {
+			var opts []smap.Option
+			if bloom {
+				opts = append(opts, smap.WithBloomFilter(fibonacciHash, bloomBenchmarkKeys, 0.01))
+			}
+			sm := smap.NewInteger[int, int](64, bloomBenchmarkKeys/64, opts...)
+			for i := 0; i < bloomBenchmarkKeys; i++ {
+				sm.Store(i*10, i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Int()
+				for pb.Next() {
+					key := i % (bloomBenchmarkKeys * 10)
+					if i%100 == 0 {
+						// present keys are overwritten, so filter is not changed
+						sm.Store(key-key%10, i)
+					} else {
+						sm.Load(key)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
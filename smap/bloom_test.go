@@ -0,0 +1,70 @@
+package smap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneric_BloomFilter(t *testing.T) {
+	m := NewInteger[int, int](8, 0, WithBloomFilter(intHash, 8*1024, 0.01))
+	for i := 0; i < 8*1024; i++ {
+		m.Store(i, i)
+	}
+	for i := 0; i < 8*1024; i++ {
+		v, ok := m.Load(i)
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+
+	falsePositives := 0
+	for i := 8 * 1024; i < 16*8*1024; i++ {
+		if m.mayContain(m.ShardID(i), i) {
+			falsePositives++
+		}
+		_, ok := m.Load(i)
+		assert.False(t, ok)
+	}
+	assert.Less(t, float64(falsePositives)/(15*8*1024), 0.02)
+
+	// deleted keys are removed from filter, overwrites do not increment counters
+	for i := 0; i < 8*1024; i++ {
+		m.Store(i, -i)
+		if i%2 == 0 {
+			m.Delete(i)
+		}
+	}
+	_, _ = m.LoadAndDelete(1)
+	m.WithValue(3, func(v *int, exists bool) bool {
+		return false
+	})
+	for i := 0; i < 8*1024; i++ {
+		_, ok := m.Load(i)
+		assert.Equal(t, i%2 == 1 && i != 1 && i != 3, ok, "key %d", i)
+	}
+	falsePositives = 0
+	for i := 0; i < 8*1024; i += 2 {
+		if m.mayContain(m.ShardID(i), i) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, float64(falsePositives)/(4*1024), 0.05)
+}
+
+func TestBloomFilter_SaturatedCounters(t *testing.T) {
+	_, filters := newBloomFilters[int](1, options{bloomHash: intHash, bloomItems: 1, bloomFalsePositiveRate: 0.5})
+	f := &filters[0]
+	for i := 0; i < 300; i++ {
+		f.add(intHash(1))
+	}
+	for i := 0; i < 300; i++ {
+		f.remove(intHash(1))
+	}
+	assert.True(t, f.mayContain(intHash(1)), "saturated counters should never be decremented")
+}
+
+func TestWithBloomFilter_KeyTypeMismatch(t *testing.T) {
+	assert.Panics(t, func() {
+		NewInteger[int64, int](8, 0, WithBloomFilter(intHash, 1024, 0.01))
+	})
+}
@@ -0,0 +1,391 @@
+package smap
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+const (
+	// bytesEntryHeaderSize is size of entry header in arena: entry length, key length, value length and key hash.
+	bytesEntryHeaderSize = 4 + 4 + 4 + 8
+	bytesMinArenaSize    = 1 << 12
+)
+
+// ErrEntryTooLarge is returned when entry could not fit into shard arena.
+var ErrEntryTooLarge = errors.New("smap: entry is larger than shard arena")
+
+// Bytes is sharded map with string keys and byte slice values, designed for very large caches.
+// Entries are serialized to per-shard ring-buffer byte arenas, and shard index maps key hash to entry offset,
+// so the map contains no pointers, and does not increase GC mark time, whatever entries count is.
+//
+// Bytes has cache semantics: when shard arena is full, oldest entries are evicted.
+// Keys are always compared with stored bytes. When two keys have the same 64-bit hash, the later one is indexed
+// in small per-shard overflow map by the key itself, so colliding keys coexist.
+type Bytes struct {
+	shards []bytesShard
+}
+
+type bytesShard struct {
+	lock  sync.RWMutex
+	index map[uint64]uint32
+	// overflow indexes keys, whose hash is already taken in index by other key. It's nil until the first collision.
+	overflow map[string]uint32
+	arena    []byte
+	// head and tail are virtual positions of the oldest entry and of the next entry.
+	// Physical offset in arena is position modulo arena length.
+	head    uint64
+	tail    uint64
+	live    int
+	maxSize int
+}
+
+// NewBytes creates sharded byte-arena map.
+// shardCapacity is max arena size of each shard in bytes, arenas grow up to it on demand.
+func NewBytes(shardsCount, shardCapacity int) Bytes {
+	if shardCapacity < bytesEntryHeaderSize {
+		shardCapacity = bytesEntryHeaderSize
+	}
+	if shardCapacity > math.MaxUint32 {
+		shardCapacity = math.MaxUint32
+	}
+	initialSize := shardCapacity
+	if initialSize > bytesMinArenaSize {
+		initialSize = bytesMinArenaSize
+	}
+
+	sm := Bytes{
+		shards: make([]bytesShard, shardsCount),
+	}
+	for i := range sm.shards {
+		sm.shards[i].index = make(map[uint64]uint32)
+		sm.shards[i].arena = make([]byte, initialSize)
+		sm.shards[i].maxSize = shardCapacity
+	}
+	return sm
+}
+
+// Load returns a copy of the value stored in the map for a key.
+// The ok result indicates whether value was found in the map.
+func (sm Bytes) Load(key string) ([]byte, bool) {
+	return sm.AppendValue(nil, key)
+}
+
+// AppendValue appends the value stored in the map for a key to dst, and returns the extended buffer.
+// Could be used to avoid allocations on loading.
+// The ok result indicates whether value was found in the map.
+func (sm Bytes) AppendValue(dst []byte, key string) ([]byte, bool) {
+	hash := shard.FNV64a(key)
+	s := &sm.shards[hash%uint64(len(sm.shards))]
+	s.lock.RLock()
+	entry, ok := s.find(hash, key)
+	if ok {
+		dst = append(dst, entry.value()...)
+	}
+	s.lock.RUnlock()
+	return dst, ok
+}
+
+// Store sets the value for a key. Value is copied to the map.
+// If stored value is not longer than the previous value for the key, it is overwritten in place.
+// Returns ErrEntryTooLarge if entry can't fit into shard arena.
+func (sm Bytes) Store(key string, value []byte) error {
+	hash := shard.FNV64a(key)
+	s := &sm.shards[hash%uint64(len(sm.shards))]
+	if bytesEntryHeaderSize+len(key)+len(value) > s.maxSize {
+		return ErrEntryTooLarge
+	}
+	s.lock.Lock()
+	s.set(hash, key, value)
+	s.lock.Unlock()
+	return nil
+}
+
+// Delete deletes the value for a key.
+func (sm Bytes) Delete(key string) {
+	hash := shard.FNV64a(key)
+	s := &sm.shards[hash%uint64(len(sm.shards))]
+	s.lock.Lock()
+	if entry, overflow, ok := s.lookup(hash, key); ok {
+		s.unlink(hash, key, overflow)
+		s.live -= entry.len()
+	}
+	s.lock.Unlock()
+}
+
+// Len returns count of entries in the map.
+func (sm Bytes) Len() int {
+	count := 0
+	for i := range sm.shards {
+		sm.shards[i].lock.RLock()
+		count += len(sm.shards[i].index) + len(sm.shards[i].overflow)
+		sm.shards[i].lock.RUnlock()
+	}
+	return count
+}
+
+// Range calls cb sequentially for each key and value present in the map.
+// If cb returns false, range stops the iteration.
+// Shard entries are copied under read lock, so cb could call any method on sm, and could retain value.
+func (sm Bytes) Range(cb func(key string, value []byte) bool) {
+	var (
+		keys   []string
+		values [][]byte
+	)
+	for i := range sm.shards {
+		keys, values = keys[:0], values[:0]
+		s := &sm.shards[i]
+		s.lock.RLock()
+		s.walk(func(entry bytesEntry, live bool) {
+			if live {
+				keys = append(keys, string(entry.key()))
+				values = append(values, append([]byte(nil), entry.value()...))
+			}
+		})
+		s.lock.RUnlock()
+
+		for j := range keys {
+			if !cb(keys[j], values[j]) {
+				return
+			}
+		}
+	}
+}
+
+// Compact rewrites live entries of each shard to a new arena, dropping space used by deleted and overwritten entries.
+// Compaction is also done automatically, when arena is full and contains enough dead entries.
+func (sm Bytes) Compact() {
+	for i := range sm.shards {
+		s := &sm.shards[i]
+		s.lock.Lock()
+		s.compact(len(s.arena))
+		s.lock.Unlock()
+	}
+}
+
+// bytesEntry is entry slice of arena, starting with header.
+type bytesEntry []byte
+
+func (e bytesEntry) len() int {
+	return int(binary.LittleEndian.Uint32(e[0:4]))
+}
+
+func (e bytesEntry) key() []byte {
+	keyLen := binary.LittleEndian.Uint32(e[4:8])
+	return e[bytesEntryHeaderSize : bytesEntryHeaderSize+keyLen]
+}
+
+func (e bytesEntry) value() []byte {
+	keyLen := binary.LittleEndian.Uint32(e[4:8])
+	valueLen := binary.LittleEndian.Uint32(e[8:12])
+	return e[bytesEntryHeaderSize+keyLen : bytesEntryHeaderSize+keyLen+valueLen]
+}
+
+func (e bytesEntry) valueCapacity() int {
+	return e.len() - bytesEntryHeaderSize - int(binary.LittleEndian.Uint32(e[4:8]))
+}
+
+func (e bytesEntry) hash() uint64 {
+	return binary.LittleEndian.Uint64(e[12:20])
+}
+
+func (s *bytesShard) find(hash uint64, key string) (bytesEntry, bool) {
+	entry, _, ok := s.lookup(hash, key)
+	return entry, ok
+}
+
+// lookup returns entry of the key, and reports whether it's indexed in overflow map.
+func (s *bytesShard) lookup(hash uint64, key string) (bytesEntry, bool, bool) {
+	if offset, ok := s.index[hash]; ok {
+		if entry := bytesEntry(s.arena[offset:]); string(entry.key()) == key {
+			return entry, false, true
+		}
+	}
+	// key could be in overflow map, even if colliding key in index is already deleted
+	if offset, ok := s.overflow[key]; ok {
+		return bytesEntry(s.arena[offset:]), true, true
+	}
+	return nil, false, false
+}
+
+// unlink removes key from index or overflow map.
+func (s *bytesShard) unlink(hash uint64, key string, overflow bool) {
+	if overflow {
+		delete(s.overflow, key)
+	} else {
+		delete(s.index, hash)
+	}
+}
+
+// link adds key entry at offset to index, or to overflow map, if hash is taken by other key.
+func (s *bytesShard) link(hash uint64, key string, offset uint32) {
+	if _, taken := s.index[hash]; !taken {
+		s.index[hash] = offset
+		return
+	}
+	if s.overflow == nil {
+		s.overflow = make(map[string]uint32)
+	}
+	s.overflow[key] = offset
+}
+
+// isLive reports whether entry at offset is indexed.
+func (s *bytesShard) isLive(entry bytesEntry, offset uint64) bool {
+	if indexed, ok := s.index[entry.hash()]; ok && uint64(indexed) == offset {
+		return true
+	}
+	if len(s.overflow) == 0 {
+		return false
+	}
+	indexed, ok := s.overflow[string(entry.key())]
+	return ok && uint64(indexed) == offset
+}
+
+func (s *bytesShard) set(hash uint64, key string, value []byte) {
+	if entry, overflow, ok := s.lookup(hash, key); ok {
+		if len(value) <= entry.valueCapacity() {
+			binary.LittleEndian.PutUint32(entry[8:12], uint32(len(value)))
+			copy(entry[bytesEntryHeaderSize+len(key):], value)
+			return
+		}
+		s.unlink(hash, key, overflow)
+		s.live -= entry.len()
+	}
+
+	size := bytesEntryHeaderSize + len(key) + len(value)
+	s.reserve(size)
+	offset := s.tail % uint64(len(s.arena))
+	entry := bytesEntry(s.arena[offset : offset+uint64(size)])
+	binary.LittleEndian.PutUint32(entry[0:4], uint32(size))
+	binary.LittleEndian.PutUint32(entry[4:8], uint32(len(key)))
+	binary.LittleEndian.PutUint32(entry[8:12], uint32(len(value)))
+	binary.LittleEndian.PutUint64(entry[12:20], hash)
+	copy(entry[bytesEntryHeaderSize:], key)
+	copy(entry[bytesEntryHeaderSize+len(key):], value)
+
+	s.link(hash, key, uint32(offset))
+	s.live += size
+	s.tail += uint64(size)
+}
+
+// reserve makes contiguous space for size bytes at tail: compacts or grows arena if it's worth it,
+// and evicts the oldest entries otherwise.
+func (s *bytesShard) reserve(size int) {
+	if s.padding(size)+uint64(size) <= s.free() {
+		return
+	}
+
+	newSize := len(s.arena)
+	for newSize < s.maxSize && s.live+size > newSize/2 {
+		newSize *= 2
+	}
+	if newSize > s.maxSize {
+		newSize = s.maxSize
+	}
+	if newSize != len(s.arena) || int(s.tail-s.head)-s.live >= len(s.arena)/4 {
+		s.compact(newSize)
+	}
+
+	for s.padding(size)+uint64(size) > s.free() {
+		if s.head == s.tail {
+			s.head, s.tail = 0, 0
+			continue
+		}
+		s.evict()
+	}
+
+	if padding := s.padding(size); padding > 0 {
+		if padding >= bytesEntryHeaderSize {
+			offset := s.tail % uint64(len(s.arena))
+			binary.LittleEndian.PutUint32(s.arena[offset:], 0) // wrap marker
+		}
+		s.tail += padding
+	}
+}
+
+// padding returns count of bytes, that should be skipped at the end of arena to place entry contiguously.
+func (s *bytesShard) padding(size int) uint64 {
+	offset := s.tail % uint64(len(s.arena))
+	if offset+uint64(size) <= uint64(len(s.arena)) {
+		return 0
+	}
+	return uint64(len(s.arena)) - offset
+}
+
+func (s *bytesShard) free() uint64 {
+	return uint64(len(s.arena)) - (s.tail - s.head)
+}
+
+// evict removes the oldest entry from arena.
+func (s *bytesShard) evict() {
+	offset, entry := s.entryAt(s.head)
+	if entry == nil {
+		s.head += uint64(len(s.arena)) - offset
+		return
+	}
+	if indexed, ok := s.index[entry.hash()]; ok && uint64(indexed) == offset {
+		delete(s.index, entry.hash())
+		s.live -= entry.len()
+	} else if indexed, ok := s.overflow[string(entry.key())]; ok && uint64(indexed) == offset {
+		delete(s.overflow, string(entry.key()))
+		s.live -= entry.len()
+	}
+	s.head += uint64(entry.len())
+}
+
+// entryAt returns physical offset for virtual position, and entry at it, or nil if wrap padding is placed there.
+func (s *bytesShard) entryAt(position uint64) (uint64, bytesEntry) {
+	offset := position % uint64(len(s.arena))
+	if uint64(len(s.arena))-offset < bytesEntryHeaderSize {
+		return offset, nil
+	}
+	entry := bytesEntry(s.arena[offset:])
+	if entry.len() == 0 {
+		return offset, nil
+	}
+	return offset, entry
+}
+
+// walk calls cb for each entry from the oldest to the newest, with flag if entry is live.
+func (s *bytesShard) walk(cb func(entry bytesEntry, live bool)) {
+	for position := s.head; position < s.tail; {
+		offset, entry := s.entryAt(position)
+		if entry == nil {
+			position += uint64(len(s.arena)) - offset
+			continue
+		}
+		cb(entry, s.isLive(entry, offset))
+		position += uint64(entry.len())
+	}
+}
+
+// compact copies live entries to the new arena of given size, keeping their order.
+// Index and overflow map are rebuilt too, since go maps never shrink.
+func (s *bytesShard) compact(size int) {
+	arena := make([]byte, size)
+	index := make(map[uint64]uint32, len(s.index))
+	var overflow map[string]uint32
+	var tail uint64
+	s.walk(func(entry bytesEntry, live bool) {
+		if !live {
+			return
+		}
+		copy(arena[tail:], entry[:entry.len()])
+		if _, taken := index[entry.hash()]; !taken {
+			index[entry.hash()] = uint32(tail)
+		} else {
+			if overflow == nil {
+				overflow = make(map[string]uint32)
+			}
+			overflow[string(entry.key())] = uint32(tail)
+		}
+		tail += uint64(entry.len())
+	})
+	s.arena = arena
+	s.index = index
+	s.overflow = overflow
+	s.head, s.tail = 0, tail
+}
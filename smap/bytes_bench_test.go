@@ -0,0 +1,98 @@
+package smap_test
+
+import (
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lispad/go-generics-tools/smap"
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+const gcBenchmarkEntries = 1 << 20
+
+func BenchmarkBytes_GCPause(b *testing.B) {
+	sm := smap.NewBytes(smap.HeuristicOptimalShardsCount(), 1<<24)
+	value := make([]byte, 64)
+	for i := 0; i < gcBenchmarkEntries; i++ {
+		_ = sm.Store(strconv.Itoa(i), value)
+	}
+	benchmarkGCPause(b)
+	runtime.KeepAlive(sm)
+}
+
+func BenchmarkGeneric_GCPause(b *testing.B) {
+	shards, shardSize := smap.HeuristicOptimalDistribution(gcBenchmarkEntries)
+	sm := smap.NewGeneric[string, []byte](shards, shardSize, shard.Must(shard.FNV(shards)))
+	for i := 0; i < gcBenchmarkEntries; i++ {
+		sm.Store(strconv.Itoa(i), make([]byte, 64))
+	}
+	benchmarkGCPause(b)
+	runtime.KeepAlive(sm)
+}
+
+func BenchmarkBytes_ConcurrentGetSet5(b *testing.B) {
+	sm := smap.NewBytes(smap.HeuristicOptimalShardsCount(), 1<<20)
+	keys := benchmarkStringKeys()
+	value := make([]byte, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := uint16(rand.Uint32())
+		var buf []byte
+		for pb.Next() {
+			if i%20 == 0 {
+				_ = sm.Store(keys[i], value)
+			} else {
+				buf, _ = sm.AppendValue(buf[:0], keys[i])
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkGeneric_ConcurrentGetSet5(b *testing.B) {
+	shards, shardSize := smap.HeuristicOptimalDistribution(1 << 16)
+	sm := smap.NewGeneric[string, []byte](shards, shardSize, shard.Must(shard.FNV(shards)))
+	keys := benchmarkStringKeys()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := uint16(rand.Uint32())
+		for pb.Next() {
+			if i%20 == 0 {
+				sm.Store(keys[i], make([]byte, 64))
+			} else {
+				sm.Load(keys[i])
+			}
+			i++
+		}
+	})
+}
+
+// benchmarkGCPause runs forced GC b.N times, and reports mean stop-the-world pause and total GC duration.
+func benchmarkGCPause(b *testing.B) {
+	runtime.GC()
+	var start, end runtime.MemStats
+	runtime.ReadMemStats(&start)
+	b.ResetTimer()
+	begin := time.Now()
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+	}
+	elapsed := time.Since(begin)
+	b.StopTimer()
+	runtime.ReadMemStats(&end)
+	b.ReportMetric(float64(end.PauseTotalNs-start.PauseTotalNs)/float64(b.N), "pause-ns/op")
+	b.ReportMetric(float64(elapsed.Microseconds())/float64(b.N), "gc-us/op")
+}
+
+func benchmarkStringKeys() []string {
+	keys := make([]string, 1<<16)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	return keys
+}
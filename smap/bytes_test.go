@@ -0,0 +1,178 @@
+package smap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytes_StoreLoadDelete(t *testing.T) {
+	m := NewBytes(8, 1<<20)
+	val, ok := m.Load("foo")
+	assert.False(t, ok)
+	assert.Nil(t, val)
+
+	assert.NoError(t, m.Store("foo", []byte("bar")))
+	val, ok = m.Load("foo")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("bar"), val)
+
+	val[0] = 'z' // loaded value is a copy
+	val, _ = m.Load("foo")
+	assert.Equal(t, []byte("bar"), val)
+
+	assert.NoError(t, m.Store("empty", nil))
+	val, ok = m.Load("empty")
+	assert.True(t, ok)
+	assert.Empty(t, val)
+	assert.Equal(t, 2, m.Len())
+
+	m.Delete("foo")
+	m.Delete("missing")
+	_, ok = m.Load("foo")
+	assert.False(t, ok)
+	assert.Equal(t, 1, m.Len())
+
+	assert.ErrorIs(t, m.Store("huge", make([]byte, 1<<20)), ErrEntryTooLarge)
+}
+
+func TestBytes_OverwriteInPlace(t *testing.T) {
+	m := NewBytes(1, 1<<20)
+	shard := &m.shards[0]
+	assert.NoError(t, m.Store("key", []byte("long value")))
+	tail := shard.tail
+
+	assert.NoError(t, m.Store("key", []byte("short")))
+	assert.Equal(t, tail, shard.tail) // value is overwritten in place
+	val, _ := m.Load("key")
+	assert.Equal(t, []byte("short"), val)
+
+	assert.NoError(t, m.Store("key", []byte("long value")))
+	assert.Equal(t, tail, shard.tail) // value capacity is kept
+	val, _ = m.Load("key")
+	assert.Equal(t, []byte("long value"), val)
+
+	assert.NoError(t, m.Store("key", []byte("much longer value")))
+	assert.Greater(t, shard.tail, tail) // entry is moved to the tail
+	assert.Equal(t, len(shard.index), 1)
+	val, _ = m.Load("key")
+	assert.Equal(t, []byte("much longer value"), val)
+}
+
+func TestBytes_HashCollision(t *testing.T) {
+	m := NewBytes(1, 1<<20)
+	shard := &m.shards[0]
+	shard.set(42, "first", []byte("1"))
+	_, ok := shard.find(42, "second") // same hash, other key
+	assert.False(t, ok)
+
+	shard.set(42, "second", []byte("2")) // colliding keys coexist
+	shard.set(42, "third", []byte("3"))
+	for key, value := range map[string]string{"first": "1", "second": "2", "third": "3"} {
+		entry, ok := shard.find(42, key)
+		assert.True(t, ok)
+		assert.Equal(t, []byte(value), entry.value())
+	}
+	assert.Equal(t, 1, len(shard.index))
+	assert.Equal(t, 2, len(shard.overflow))
+
+	shard.set(42, "second", []byte("longer value")) // moved to the tail
+	shard.compact(len(shard.arena))
+	entry, ok := shard.find(42, "second")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("longer value"), entry.value())
+
+	entry, _ = shard.find(42, "first")
+	shard.unlink(42, "first", false)
+	shard.live -= entry.len()
+	_, ok = shard.find(42, "first")
+	assert.False(t, ok)
+	entry, ok = shard.find(42, "third")
+	assert.True(t, ok, "overflow key is found after indexed key is deleted")
+	assert.Equal(t, []byte("3"), entry.value())
+
+	shard.set(42, "fourth", []byte("4")) // hash is free in index again
+	_, ok = shard.find(42, "fourth")
+	assert.True(t, ok)
+	assert.Equal(t, 3, len(shard.index)+len(shard.overflow))
+}
+
+func TestBytes_HashCollisionEviction(t *testing.T) {
+	m := NewBytes(1, 256)
+	shard := &m.shards[0]
+	shard.set(42, "first", make([]byte, 100))
+	shard.set(42, "second", make([]byte, 100))
+	shard.set(7, "third", make([]byte, 100)) // evicts first
+	_, ok := shard.find(42, "first")
+	assert.False(t, ok)
+	assert.Equal(t, 2, len(shard.index)+len(shard.overflow))
+	assert.Equal(t, 2, m.Len())
+}
+
+func TestBytes_EvictionAndGrowth(t *testing.T) {
+	const capacity = 1 << 17
+	m := NewBytes(1, capacity)
+	shard := &m.shards[0]
+	value := []byte(strings.Repeat("v", 100))
+	entrySize := bytesEntryHeaderSize + len("key-00000") + len(value)
+
+	stored := 3 * capacity / entrySize
+	for i := 0; i < stored; i++ {
+		assert.NoError(t, m.Store(fmt.Sprintf("key-%05d", i), value))
+	}
+	assert.Equal(t, capacity, len(shard.arena)) // grown to max size
+	assert.Less(t, m.Len(), stored)
+	assert.Greater(t, m.Len(), capacity/entrySize/2)
+
+	_, ok := m.Load("key-00000") // the oldest entries are evicted
+	assert.False(t, ok)
+	for i := stored - m.Len(); i < stored; i++ { // the newest are kept
+		val, ok := m.Load(fmt.Sprintf("key-%05d", i))
+		assert.True(t, ok)
+		assert.Equal(t, value, val)
+	}
+	assert.Equal(t, m.Len()*entrySize, shard.live)
+}
+
+func TestBytes_Compact(t *testing.T) {
+	m := NewBytes(1, 1<<20)
+	shard := &m.shards[0]
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, m.Store(fmt.Sprint(i), []byte(fmt.Sprint("value ", i))))
+	}
+	for i := 0; i < 100; i += 2 {
+		m.Delete(fmt.Sprint(i))
+	}
+	used := shard.tail - shard.head
+
+	m.Compact()
+	assert.Equal(t, uint64(shard.live), shard.tail-shard.head)
+	assert.Less(t, shard.tail-shard.head, used)
+	assert.Equal(t, 50, m.Len())
+	for i := 1; i < 100; i += 2 {
+		val, ok := m.Load(fmt.Sprint(i))
+		assert.True(t, ok)
+		assert.Equal(t, []byte(fmt.Sprint("value ", i)), val)
+	}
+}
+
+func TestBytes_Range(t *testing.T) {
+	m := NewBytes(8, 1<<20)
+	expected := make(map[string]string, 256)
+	for i := 0; i < 256; i++ {
+		key, value := fmt.Sprint(i), fmt.Sprint(i*i)
+		assert.NoError(t, m.Store(key, []byte(value)))
+		expected[key] = value
+	}
+	m.Delete("0")
+	delete(expected, "0")
+
+	result := make(map[string]string, 256)
+	m.Range(func(key string, value []byte) bool {
+		result[key] = string(value)
+		return true
+	})
+	assert.Equal(t, expected, result)
+}
@@ -0,0 +1,21 @@
+//go:build !smapdebug
+
+package smap
+
+// lockOwners tracks goroutines, holding shard lock, in checked mode, enabled by smapdebug build tag.
+// Without the tag all checks are no-op.
+type lockOwners struct{}
+
+// checkedMode is true, when smap is built with smapdebug tag.
+const checkedMode = false
+
+func (o *lockOwners) init(int)     {}
+func (o *lockOwners) beforeLock()  {}
+func (o *lockOwners) locked()      {}
+func (o *lockOwners) unlocked()    {}
+func (o *lockOwners) beforeRLock() {}
+func (o *lockOwners) rlocked()     {}
+func (o *lockOwners) runlocked()   {}
+
+// checkLocked verifies in checked mode, that current goroutine holds lock of the shard, and it's write lock if write is true.
+func (sm Generic[K, V]) checkLocked(string, int, bool) {}
@@ -0,0 +1,135 @@
+//go:build smapdebug
+
+package smap
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// checkedMode is true, when smap is built with smapdebug tag.
+const checkedMode = true
+
+// lockOwners tracks goroutines, holding shard lock, in checked mode, enabled by smapdebug build tag.
+// Like sync.RWMutex, lock could be released by other goroutine, than the one which acquired it.
+type lockOwners struct {
+	mu      sync.Mutex
+	shardID int
+	writer  int64 // goroutine id, zero if shard is not write locked
+	readers map[int64]int
+}
+
+func (o *lockOwners) init(shardID int) {
+	o.shardID = shardID
+	o.readers = make(map[int64]int)
+}
+
+func (o *lockOwners) beforeLock() {
+	gid := goroutineID()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.writer == gid {
+		panic(fmt.Sprintf("smap: shard %d is already write locked by this goroutine, locking it again deadlocks", o.shardID))
+	}
+	if o.readers[gid] > 0 {
+		panic(fmt.Sprintf("smap: shard %d is read locked by this goroutine, write locking it deadlocks", o.shardID))
+	}
+}
+
+func (o *lockOwners) locked() {
+	gid := goroutineID()
+	o.mu.Lock()
+	o.writer = gid
+	o.mu.Unlock()
+}
+
+func (o *lockOwners) unlocked() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.writer == 0 {
+		panic(fmt.Sprintf("smap: unlock of shard %d, which is not write locked", o.shardID))
+	}
+	o.writer = 0
+}
+
+func (o *lockOwners) beforeRLock() {
+	gid := goroutineID()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.writer == gid {
+		panic(fmt.Sprintf("smap: shard %d is write locked by this goroutine, read locking it deadlocks", o.shardID))
+	}
+	if o.readers[gid] > 0 {
+		panic(fmt.Sprintf("smap: shard %d is already read locked by this goroutine, recursive read lock deadlocks with waiting writer", o.shardID))
+	}
+}
+
+func (o *lockOwners) rlocked() {
+	gid := goroutineID()
+	o.mu.Lock()
+	o.readers[gid]++
+	o.mu.Unlock()
+}
+
+func (o *lockOwners) runlocked() {
+	gid := goroutineID()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.readers[gid] == 0 {
+		// released by other goroutine, than the one which acquired the lock
+		for reader := range o.readers {
+			gid = reader
+			break
+		}
+	}
+	if o.readers[gid] == 0 {
+		panic(fmt.Sprintf("smap: read unlock of shard %d, which is not read locked", o.shardID))
+	}
+	if o.readers[gid]--; o.readers[gid] == 0 {
+		delete(o.readers, gid)
+	}
+}
+
+// heldBy reports whether goroutine holds read or write lock, and whether it's write lock.
+func (o *lockOwners) heldBy(gid int64) (held, write bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.writer == gid {
+		return true, true
+	}
+	return o.readers[gid] > 0, false
+}
+
+// checkLocked verifies that current goroutine holds lock of the shard, and it's write lock if write is true.
+func (sm Generic[K, V]) checkLocked(op string, shardID int, write bool) {
+	gid := goroutineID()
+	held, writeHeld := sm.locks[shardID].ext.owners.heldBy(gid)
+	if !held {
+		for id := range sm.locks {
+			if other, _ := sm.locks[id].ext.owners.heldBy(gid); other {
+				panic(fmt.Sprintf("smap: %s accesses shard %d, while this goroutine holds lock of shard %d", op, shardID, id))
+			}
+		}
+		panic(fmt.Sprintf("smap: %s accesses shard %d without lock", op, shardID))
+	}
+	if write && !writeHeld {
+		panic(fmt.Sprintf("smap: %s writes to shard %d under read lock", op, shardID))
+	}
+}
+
+// goroutineID parses current goroutine id from stack trace header. It's slow, and is used only in checked mode.
+func goroutineID() int64 {
+	var buf [64]byte
+	header := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+	id, err := strconv.ParseInt(string(header), 10, 64)
+	if err != nil {
+		panic("smap: can't parse goroutine id: " + err.Error())
+	}
+	return id
+}
@@ -0,0 +1,81 @@
+//go:build smapdebug
+
+package smap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecked_UnblockedWithoutLock(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	assert.PanicsWithValue(t, "smap: UnblockedGet accesses shard 1 without lock", func() {
+		m.UnblockedGet(1)
+	})
+	assert.PanicsWithValue(t, "smap: UnblockedShardRange accesses shard 2 without lock", func() {
+		m.UnblockedShardRange(2, func(int, int) bool { return true })
+	})
+}
+
+func TestChecked_WrongShard(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	m.LockShard(0)
+	defer m.UnlockShard(0)
+	assert.PanicsWithValue(t, "smap: UnblockedSet accesses shard 1, while this goroutine holds lock of shard 0", func() {
+		m.UnblockedSet(1, 1)
+	})
+	m.UnblockedSet(4, 4)
+}
+
+func TestChecked_WriteUnderReadLock(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	m.RLockShard(1)
+	defer m.RUnlockShard(1)
+	assert.PanicsWithValue(t, "smap: UnblockedSet writes to shard 1 under read lock", func() {
+		m.UnblockedSet(1, 1)
+	})
+	_, ok := m.UnblockedGet(1)
+	assert.False(t, ok)
+}
+
+func TestChecked_DoubleLock(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	m.LockShard(1)
+	assert.PanicsWithValue(t, "smap: shard 1 is already write locked by this goroutine, locking it again deadlocks", func() {
+		m.Store(1, 1)
+	})
+	assert.PanicsWithValue(t, "smap: shard 1 is write locked by this goroutine, read locking it deadlocks", func() {
+		m.Load(5)
+	})
+	m.UnlockShard(1)
+
+	m.RLockShard(2)
+	assert.PanicsWithValue(t, "smap: shard 2 is read locked by this goroutine, write locking it deadlocks", func() {
+		m.Delete(2)
+	})
+	m.RUnlockShard(2)
+	assert.PanicsWithValue(t, "smap: read unlock of shard 2, which is not read locked", func() {
+		m.RUnlockShard(2)
+	})
+
+	// locks are released, so map could be used again
+	m.Store(1, 1)
+	v, ok := m.Load(1)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+}
+
+func TestChecked_OtherGoroutine(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	m.LockShard(1)
+	done := make(chan interface{})
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		m.UnblockedGet(1)
+	}()
+	assert.Equal(t, "smap: UnblockedGet accesses shard 1 without lock", <-done)
+	m.UnlockShard(1)
+}
@@ -0,0 +1,45 @@
+package smap
+
+// minAutoCompactionDeletions is minimal count of deletions in shard, that could trigger auto compaction.
+const minAutoCompactionDeletions = 64
+
+// Compact rebuilds all shard maps, sizing them to their current length.
+// Go maps never release buckets, so Compact could be used to free memory after mass deletion.
+// Shards are locked one by one.
+func (sm Generic[K, V]) Compact() {
+	for i := range sm.locks {
+		sm.CompactShard(i)
+	}
+}
+
+// CompactShard rebuilds map of shard with given id, sizing it to current shard length.
+func (sm Generic[K, V]) CompactShard(id int) {
+	sm.locks[id].Lock()
+	sm.compactShard(id)
+	sm.locks[id].Unlock()
+}
+
+// compactShard should be called only under shard write lock.
+func (sm Generic[K, V]) compactShard(id int) {
+	shard := make(map[K]V, len(sm.shards[id]))
+	for key, value := range sm.shards[id] {
+		shard[key] = value
+	}
+	sm.shards[id] = shard
+	if sm.deletions != nil {
+		sm.deletions[id] = 0
+	}
+}
+
+// deleted counts deletions in shard for auto compaction policy, and compacts shard when threshold is exceeded.
+// Should be called only under shard write lock.
+func (sm Generic[K, V]) deleted(id int, count int) {
+	if sm.deletions == nil {
+		return
+	}
+	sm.deletions[id] += count
+	if deletions := sm.deletions[id]; deletions >= minAutoCompactionDeletions &&
+		float64(deletions) > sm.compactionRatio*float64(len(sm.shards[id])) {
+		sm.compactShard(id)
+	}
+}
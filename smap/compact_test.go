@@ -0,0 +1,72 @@
+package smap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneric_Compact(t *testing.T) {
+	m := NewInteger[int, int](8, 128)
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i*i)
+	}
+	for i := 0; i < 1024; i += 4 {
+		m.Delete(i)
+	}
+	shard := reflect.ValueOf(m.shards[1]).Pointer()
+
+	m.CompactShard(1)
+	assert.NotEqual(t, shard, reflect.ValueOf(m.shards[1]).Pointer()) // map is rebuilt
+	m.Compact()
+
+	for i := 0; i < 1024; i++ {
+		val, ok := m.Load(i)
+		assert.Equal(t, i%4 != 0, ok)
+		if ok {
+			assert.Equal(t, i*i, val)
+		}
+	}
+}
+
+func TestGeneric_AutoCompaction(t *testing.T) {
+	m := NewInteger[int, int](2, 128, WithAutoCompaction(0.5))
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i)
+	}
+	shard := reflect.ValueOf(m.shards[0]).Pointer()
+
+	m.Delete(0)
+	m.Delete(0) // missing key deletions are not counted
+	_, _ = m.LoadAndDelete(2)
+	_, _ = m.LoadAndDelete(2)
+	assert.Equal(t, 2, m.deletions[0])
+
+	// shard is compacted when deletions count exceeds half of shard length: 512 - 171 < 171 * 2
+	for i := 4; i < 2*170; i += 2 {
+		m.Delete(i)
+	}
+	assert.Equal(t, 170, m.deletions[0])
+	assert.Equal(t, shard, reflect.ValueOf(m.shards[0]).Pointer())
+
+	m.Delete(2 * 170)
+	assert.Equal(t, 0, m.deletions[0])
+	assert.NotEqual(t, shard, reflect.ValueOf(m.shards[0]).Pointer())
+	assert.Equal(t, 512-171, len(m.shards[0]))
+	assert.Equal(t, 512, len(m.shards[1]))
+}
+
+func TestGeneric_AutoCompactionSmallShard(t *testing.T) {
+	m := NewInteger[int, int](1, 128, WithAutoCompaction(0.5))
+	for i := 0; i < minAutoCompactionDeletions; i++ {
+		m.Store(i, i)
+	}
+	shard := reflect.ValueOf(m.shards[0]).Pointer()
+	for i := 0; i < minAutoCompactionDeletions-1; i++ {
+		m.Delete(i)
+	}
+	assert.Equal(t, shard, reflect.ValueOf(m.shards[0]).Pointer()) // too few deletions
+	m.Delete(minAutoCompactionDeletions - 1)
+	assert.NotEqual(t, shard, reflect.ValueOf(m.shards[0]).Pointer())
+}
@@ -8,9 +8,9 @@ type GenericComparable[K comparable, V comparable] struct {
 
 // NewGenericComparable creates generic RWLocked Sharded map for comparable values.
 // shardDetector should be idempotent function.
-func NewGenericComparable[K comparable, V comparable](shardsCount, defaultSize int, shardDetector func(key K) int) GenericComparable[K, V] {
+func NewGenericComparable[K comparable, V comparable](shardsCount, defaultSize int, shardDetector func(key K) int, opts ...Option) GenericComparable[K, V] {
 	return GenericComparable[K, V]{
-		Generic: NewGeneric[K, V](shardsCount, defaultSize, shardDetector),
+		Generic: NewGeneric[K, V](shardsCount, defaultSize, shardDetector, opts...),
 	}
 }
 
@@ -24,9 +24,11 @@ func (sm GenericComparable[K, V]) CompareAndSwap(key K, old, new V) (V, bool) {
 	if current, ok := sm.shards[shardID][key]; ok && current == old {
 		sm.shards[shardID][key] = new
 		sm.locks[shardID].Unlock()
+		sm.locks[shardID].countOp(statStore)
 		return new, true
 	} else {
 		sm.locks[shardID].Unlock()
+		sm.locks[shardID].countOp(statLoad)
 		return current, false
 	}
 }
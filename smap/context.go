@@ -0,0 +1,103 @@
+package smap
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+const (
+	lockSpinAttempts = 16
+	lockMinBackoff   = time.Microsecond
+	lockMaxBackoff   = time.Millisecond
+)
+
+// TryLockShard tries to lock shard with given id and reports whether it succeeded.
+// Could be useful with Unblocked* functions, when caller prefers to fail fast instead of waiting.
+func (sm Generic[K, V]) TryLockShard(id int) bool {
+	return sm.locks[id].TryLock()
+}
+
+// TryRLockShard tries to lock for read shard with given id and reports whether it succeeded.
+func (sm Generic[K, V]) TryRLockShard(id int) bool {
+	return sm.locks[id].TryRLock()
+}
+
+// LockShardContext locks shard with given id, or returns ctx error if ctx is done before lock is acquired.
+// Lock is acquired with TryLock and backoff, so waiting writer does not block new readers,
+// and could wait longer than LockShard under heavy read load.
+func (sm Generic[K, V]) LockShardContext(ctx context.Context, id int) error {
+	return acquireContext(ctx, sm.locks[id].TryLock)
+}
+
+// RLockShardContext locks for read shard with given id, or returns ctx error if ctx is done before lock is acquired.
+func (sm Generic[K, V]) RLockShardContext(ctx context.Context, id int) error {
+	return acquireContext(ctx, sm.locks[id].TryRLock)
+}
+
+// LoadCtx returns the value stored in the map for a key, like Load does.
+// Returns ctx error if ctx is done before shard read lock is acquired, load is not counted in that case.
+func (sm Generic[K, V]) LoadCtx(ctx context.Context, key K) (V, bool, error) {
+	shardID := sm.shardDetector(key)
+	var zero V
+	if !sm.mayContain(shardID, key) {
+		sm.locks[shardID].countOp(statLoad)
+		return zero, false, nil
+	}
+	if err := sm.RLockShardContext(ctx, shardID); err != nil {
+		return zero, false, err
+	}
+	sm.locks[shardID].countOp(statLoad)
+	value, ok := sm.shards[shardID][key]
+	sm.locks[shardID].RUnlock()
+	return value, ok, nil
+}
+
+// StoreCtx sets the value for a key, like Store does.
+// Returns ctx error if ctx is done before shard lock is acquired, value is not stored in that case.
+func (sm Generic[K, V]) StoreCtx(ctx context.Context, key K, value V) error {
+	shardID := sm.shardDetector(key)
+	if err := sm.LockShardContext(ctx, shardID); err != nil {
+		return err
+	}
+	sm.locks[shardID].countOp(statStore)
+	sm.storing(shardID, key)
+	sm.shards[shardID][key] = value
+	sm.locks[shardID].Unlock()
+	return nil
+}
+
+// acquireContext calls tryLock until it succeeds or ctx is done.
+// First attempts just yield the processor, then sleeps with exponential backoff are used.
+func acquireContext(ctx context.Context, tryLock func() bool) error {
+	if tryLock() {
+		return nil
+	}
+	for i := 0; i < lockSpinAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		runtime.Gosched()
+		if tryLock() {
+			return nil
+		}
+	}
+
+	backoff := lockMinBackoff
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if tryLock() {
+			return nil
+		}
+		if backoff < lockMaxBackoff {
+			backoff *= 2
+		}
+		timer.Reset(backoff)
+	}
+}
@@ -0,0 +1,112 @@
+package smap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneric_TryLockShard(t *testing.T) {
+	m := NewInteger[int, int](8, 128)
+	assert.True(t, m.TryLockShard(3))
+	assert.False(t, m.TryLockShard(3))  // already locked
+	assert.False(t, m.TryRLockShard(3)) // write lock blocks readers
+	assert.True(t, m.TryLockShard(4))   // other shards are not affected
+	m.UnlockShard(3)
+	m.UnlockShard(4)
+
+	assert.True(t, m.TryRLockShard(3))
+	assert.True(t, m.TryRLockShard(3)) // several readers are allowed
+	assert.False(t, m.TryLockShard(3))
+	m.RUnlockShard(3)
+	m.RUnlockShard(3)
+	assert.True(t, m.TryLockShard(3))
+	m.UnlockShard(3)
+}
+
+func TestGeneric_LockShardContext(t *testing.T) {
+	m := NewInteger[int, int](8, 128)
+	m.LockShard(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.LockShardContext(ctx, 1), context.DeadlineExceeded)
+	assert.ErrorIs(t, m.RLockShardContext(ctx, 1), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		m.UnlockShard(1)
+	}()
+	assert.NoError(t, m.LockShardContext(context.Background(), 1)) // waits for unlock
+	m.UnlockShard(1)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, m.LockShardContext(cancelled, 1)) // free lock is acquired even with done context
+	m.UnlockShard(1)
+}
+
+func TestGeneric_StoreLoadCtx(t *testing.T) {
+	m := NewInteger[int, string](8, 128)
+	assert.NoError(t, m.StoreCtx(context.Background(), 123, "value set"))
+	val, ok, err := m.LoadCtx(context.Background(), 123)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value set", val)
+
+	m.LockShard(m.ShardID(123))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.StoreCtx(ctx, 123, "other value"), context.DeadlineExceeded)
+	val, ok, err = m.LoadCtx(ctx, 123)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, ok)
+	assert.Equal(t, "", val)
+	m.UnlockShard(m.ShardID(123))
+
+	val, ok = m.Load(123) // value was not changed by timed out store
+	assert.True(t, ok)
+	assert.Equal(t, "value set", val)
+}
+
+func TestGeneric_StoreLoadCtxOptions(t *testing.T) {
+	m := NewInteger[int, int](4, 0, WithStats(), WithBloomFilter(intHash, 1024, 0.01))
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, m.StoreCtx(context.Background(), i, i))
+	}
+	for i := 0; i < 100; i++ {
+		assert.True(t, m.blooms[m.ShardID(i)].mayContain(intHash(i)), "stored key is added to Bloom filter")
+	}
+	s := m.Stats()
+	assert.Equal(t, int64(100), s.Stores)
+
+	val, ok, err := m.LoadCtx(context.Background(), 5)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 5, val)
+
+	absent := 1000
+	for m.mayContain(m.ShardID(absent), absent) {
+		absent++
+	}
+	m.LockShard(m.ShardID(absent))
+	_, ok, err = m.LoadCtx(context.Background(), absent) // Bloom filter does not wait for locked shard
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	present := 0
+	for m.ShardID(present) != m.ShardID(absent) {
+		present++
+	}
+	_, _, err = m.LoadCtx(ctx, present)
+	assert.ErrorIs(t, err, context.Canceled)
+	m.UnlockShard(m.ShardID(absent))
+
+	next := m.Stats()
+	assert.Equal(t, s.Loads+2, next.Loads, "cancelled load is not counted")
+	assert.Equal(t, s.Stores, next.Stores)
+}
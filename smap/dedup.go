@@ -0,0 +1,221 @@
+package smap
+
+import (
+	"time"
+
+	"github.com/lispad/go-generics-tools/binheap"
+)
+
+const (
+	dedupDefaultExpectedKeys      = 100000
+	dedupDefaultFalsePositiveRate = 0.01
+)
+
+// DedupConfig configures DedupWindow. Zero fields, except for Window, are replaced with defaults.
+type DedupConfig[K comparable] struct {
+	// Window is period, during which key is reported as seen after it was added.
+	Window time.Duration
+	// MaxKeys limits count of remembered keys in exact mode, it's split between shards evenly. When shard is full,
+	// its oldest key is forgotten before the window ends. If zero, count of keys is not limited.
+	MaxKeys int
+	// Approximate enables approximate mode, where keys are kept in rotating Bloom filters, and Hash is required.
+	Approximate bool
+	// Hash is hash function of keys for Bloom filters in approximate mode, e.g. shard.XXHash64 for strings.
+	Hash func(key K) uint64
+	// ExpectedKeys is expected count of distinct keys per Window in approximate mode, it's split between shards evenly.
+	// Each of Generations filters is sized for ExpectedKeys, since all keys of the window could arrive in one epoch.
+	// MaxKeys is used by default, or 100000 if it's zero, so filters take about 1 MB per generation with default rate.
+	// If more keys arrive, false positive rate grows.
+	ExpectedKeys int
+	// FalsePositiveRate is false positive rate of Bloom filters in approximate mode, 0.01 by default,
+	// values outside of (0, 1) are replaced too.
+	FalsePositiveRate float64
+	// Generations is count of rotating Bloom filters in approximate mode, 4 by default, at least 2.
+	// Key is remembered for Window and at most Window/(Generations-1) longer.
+	Generations int
+	// Now returns current time, time.Now by default. It could be replaced in tests.
+	Now func() time.Time
+}
+
+// DedupWindow is set of keys, seen during the last Window, e.g. to drop duplicate messages.
+//
+// In exact mode keys are stored in sharded map with their expiration time, and each shard keeps expiration heap,
+// so expired and evicted keys are deleted oldest-first, when shard is accessed, or by Expire.
+// With Approximate config DedupWindow works in approximate mode: each shard keeps Generations rotating
+// Bloom filters instead of keys, so memory does not depend on count of keys, but false positive rate of the filter
+// is the rate of unique keys reported as seen. Keys are never forgotten before Window in approximate mode.
+type DedupWindow[K comparable] struct {
+	m     Generic[K, int64] // key expiration time in unix nanoseconds, empty in approximate mode
+	state *dedupState[K]
+}
+
+type dedupState[K comparable] struct {
+	config     DedupConfig[K]
+	shardLimit int
+	// expirations is heap of keys ordered by expiration time for each shard, guarded by shard lock.
+	expirations []binheap.Heap[dedupEntry[K]]
+
+	// approximate mode, filters[generation][shard] and epochs are guarded by shard lock
+	filters [][]bloomFilter
+	epochs  []int64
+	span    int64 // epoch duration in nanoseconds
+}
+
+type dedupEntry[K comparable] struct {
+	key     K
+	expires int64
+}
+
+// NewDedupWindow creates deduplication set with keys distributed to shardsCount shards by shardDetector.
+// opts configure underlying map, e.g. its lock, WithBloomFilter is ignored. Panics if config is Approximate without Hash.
+func NewDedupWindow[K comparable](shardsCount int, shardDetector func(key K) int, config DedupConfig[K], opts ...Option) DedupWindow[K] {
+	config = config.withDefaults()
+	if config.Approximate && config.Hash == nil {
+		panic("smap: DedupConfig.Hash is required in approximate mode")
+	}
+	// keys are checked by DedupWindow, not by the map, so its Bloom filter would be never used
+	opts = append(append([]Option(nil), opts...), func(o *options) {
+		o.bloomHash = nil
+	})
+	d := DedupWindow[K]{
+		m: NewGeneric[K, int64](shardsCount, 0, shardDetector, opts...),
+		state: &dedupState[K]{
+			config: config,
+		},
+	}
+
+	if config.Approximate {
+		d.state.filters = make([][]bloomFilter, config.Generations)
+		for i := range d.state.filters {
+			d.state.filters[i] = newShardBloomFilters(shardsCount, config.ExpectedKeys, config.FalsePositiveRate)
+		}
+		d.state.epochs = make([]int64, shardsCount)
+		d.state.span = int64(config.Window)/int64(config.Generations-1) + 1
+		return d
+	}
+
+	if config.MaxKeys > 0 {
+		d.state.shardLimit = (config.MaxKeys + shardsCount - 1) / shardsCount
+	}
+	d.state.expirations = make([]binheap.Heap[dedupEntry[K]], shardsCount)
+	for i := range d.state.expirations {
+		d.state.expirations[i] = binheap.EmptyHeap[dedupEntry[K]](func(x, y dedupEntry[K]) bool {
+			return x.expires < y.expires
+		})
+	}
+	return d
+}
+
+func (c DedupConfig[K]) withDefaults() DedupConfig[K] {
+	if c.ExpectedKeys <= 0 {
+		c.ExpectedKeys = c.MaxKeys
+		if c.ExpectedKeys <= 0 {
+			c.ExpectedKeys = dedupDefaultExpectedKeys
+		}
+	}
+	if c.FalsePositiveRate <= 0 || c.FalsePositiveRate >= 1 {
+		c.FalsePositiveRate = dedupDefaultFalsePositiveRate
+	}
+	if c.Generations < 2 {
+		c.Generations = 4
+	}
+	if c.Now == nil {
+		c.Now = time.Now
+	}
+	return c
+}
+
+// SeenOrAdd reports whether key was added during the last Window, and adds it otherwise.
+// Window of the key starts when it's added, and is not extended by following calls.
+func (d DedupWindow[K]) SeenOrAdd(key K) bool {
+	now := d.state.config.Now().UnixNano()
+	shardID := d.m.shardDetector(key)
+	d.m.locks[shardID].Lock()
+	defer d.m.locks[shardID].Unlock()
+
+	if d.state.filters != nil {
+		return d.seenOrAddApproximate(shardID, key, now)
+	}
+
+	d.expire(shardID, now)
+	if _, ok := d.m.shards[shardID][key]; ok {
+		return true
+	}
+	expires := now + int64(d.state.config.Window)
+	d.m.shards[shardID][key] = expires
+	h := &d.state.expirations[shardID]
+	h.Push(dedupEntry[K]{key: key, expires: expires})
+	if d.state.shardLimit > 0 && h.Len() > d.state.shardLimit {
+		d.forget(shardID, h.Pop().key)
+	}
+	return false
+}
+
+// Expire deletes expired keys from all shards, and returns count of deleted keys.
+// Expired keys are deleted from shard on each SeenOrAdd call to it anyway,
+// so Expire is needed only to free memory of shards, which are not accessed. No-op in approximate mode.
+func (d DedupWindow[K]) Expire() int {
+	if d.state.filters != nil {
+		return 0
+	}
+	now := d.state.config.Now().UnixNano()
+	count := 0
+	for id := range d.m.locks {
+		d.m.locks[id].Lock()
+		count += d.expire(id, now)
+		d.m.locks[id].Unlock()
+	}
+	return count
+}
+
+// Len returns count of remembered keys, including expired ones, which are not deleted yet. Zero in approximate mode.
+func (d DedupWindow[K]) Len() int {
+	return d.m.Stats().Len()
+}
+
+// expire deletes expired keys of the shard, should be called under shard write lock.
+func (d DedupWindow[K]) expire(shardID int, now int64) int {
+	h := &d.state.expirations[shardID]
+	count := 0
+	for h.Len() > 0 && h.Peak().expires <= now {
+		d.forget(shardID, h.Pop().key)
+		count++
+	}
+	return count
+}
+
+func (d DedupWindow[K]) forget(shardID int, key K) {
+	delete(d.m.shards[shardID], key)
+	d.m.removed(shardID, key)
+	d.m.deleted(shardID, 1)
+}
+
+// seenOrAddApproximate checks all filters of the shard, and adds key to the current one.
+// Filters are rotated first: filter of each epoch, passed since the previous call, is cleared and reused,
+// so filters keep keys of the current and Generations-1 previous epochs.
+func (d DedupWindow[K]) seenOrAddApproximate(shardID int, key K, now int64) bool {
+	generations := int64(len(d.state.filters))
+	epoch := now / d.state.span
+	if last := d.state.epochs[shardID]; epoch > last {
+		from := last + 1
+		if epoch-from >= generations {
+			from = epoch - generations + 1
+		}
+		for e := from; e <= epoch; e++ {
+			counters := d.state.filters[e%generations][shardID].counters
+			for i := range counters {
+				counters[i] = 0
+			}
+		}
+		d.state.epochs[shardID] = epoch
+	}
+
+	hash := d.state.config.Hash(key)
+	for g := range d.state.filters {
+		if d.state.filters[g][shardID].mayContain(hash) {
+			return true
+		}
+	}
+	d.state.filters[epoch%generations][shardID].add(hash)
+	return false
+}
@@ -0,0 +1,174 @@
+package smap
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+// manualClock is clock for deterministic tests, advanced by test.
+type manualClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
+
+func newDedupWindow(clock *manualClock, maxKeys int, opts ...Option) DedupWindow[int] {
+	return NewDedupWindow[int](4, shard.Must(shard.Modulo[int](4)), DedupConfig[int]{
+		Window:  time.Minute,
+		MaxKeys: maxKeys,
+		Now:     clock.Now,
+	}, opts...)
+}
+
+func TestDedupWindow_SeenOrAdd(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	d := newDedupWindow(clock, 0)
+
+	assert.False(t, d.SeenOrAdd(1))
+	assert.True(t, d.SeenOrAdd(1))
+	assert.False(t, d.SeenOrAdd(2))
+
+	clock.Advance(59 * time.Second)
+	assert.True(t, d.SeenOrAdd(1))
+	assert.False(t, d.SeenOrAdd(5), "key of the same shard")
+
+	clock.Advance(time.Second)
+	assert.False(t, d.SeenOrAdd(1), "window is not extended by duplicates")
+	assert.Equal(t, 3, d.Len(), "key 2 is expired, but its shard is not accessed yet")
+	assert.Equal(t, 1, d.Expire())
+	assert.Equal(t, 2, d.Len())
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, 2, d.Expire())
+	assert.Zero(t, d.Len())
+}
+
+func TestDedupWindow_MaxKeys(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	d := newDedupWindow(clock, 8) // 2 keys per shard
+
+	for _, key := range []int{0, 4, 8} { // keys of shard 0
+		assert.False(t, d.SeenOrAdd(key))
+		clock.Advance(time.Second)
+	}
+	assert.Equal(t, 2, d.Len())
+	assert.False(t, d.SeenOrAdd(0), "the oldest key is evicted")
+	assert.True(t, d.SeenOrAdd(8))
+	assert.False(t, d.SeenOrAdd(1), "other shard is not affected")
+	assert.Equal(t, 3, d.Len())
+}
+
+func TestDedupWindow_Approximate(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	d := NewDedupWindow[int](4, shard.Must(shard.Modulo[int](4)), DedupConfig[int]{
+		Window:            time.Minute,
+		Approximate:       true,
+		Hash:              intHash,
+		ExpectedKeys:      2000,
+		FalsePositiveRate: 0.001,
+		Now:               clock.Now,
+	})
+
+	for i := 0; i < 1000; i++ {
+		assert.False(t, d.SeenOrAdd(i))
+	}
+	falsePositives := 0
+	for i := 1000; i < 2000; i++ {
+		if d.SeenOrAdd(i) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 20)
+	assert.Zero(t, d.Len())
+
+	// keys are remembered for window, and at most window/3 longer
+	clock.Advance(59 * time.Second)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, d.SeenOrAdd(i))
+	}
+	clock.Advance(21 * time.Second)
+	seen := 0
+	for i := 0; i < 1000; i++ {
+		if d.SeenOrAdd(i) {
+			seen++
+		}
+	}
+	assert.Less(t, seen, 20)
+}
+
+func TestDedupWindow_Concurrent(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	d := newDedupWindow(clock, 0)
+	var (
+		wg    sync.WaitGroup
+		lock  sync.Mutex
+		added int
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				if !d.SeenOrAdd(i) {
+					lock.Lock()
+					added++
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1000, added, "each key is added once")
+}
+
+func TestDedupWindow_Options(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	opts := make([]Option, 1, 2)
+	opts[0] = WithBloomFilter(intHash, 100, 0.01)
+	d := newDedupWindow(clock, 0, opts...)
+
+	assert.Nil(t, d.m.blooms, "map Bloom filter is not used")
+	assert.Nil(t, d.state.filters, "WithBloomFilter does not enable approximate mode")
+	assert.Nil(t, opts[:2][1], "options of caller are not modified")
+	assert.False(t, d.SeenOrAdd(1))
+	assert.True(t, d.SeenOrAdd(1))
+
+	assert.PanicsWithValue(t, "smap: DedupConfig.Hash is required in approximate mode", func() {
+		NewDedupWindow[int](4, shard.Must(shard.Modulo[int](4)), DedupConfig[int]{Window: time.Minute, Approximate: true})
+	})
+}
+
+func TestDedupWindow_ApproximateDefaults(t *testing.T) {
+	clock := &manualClock{now: time.Unix(1000, 0)}
+	d := NewDedupWindow[int](4, shard.Must(shard.Modulo[int](4)), DedupConfig[int]{
+		Window:      time.Minute,
+		Approximate: true,
+		Hash:        intHash,
+		Now:         clock.Now,
+	})
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if d.SeenOrAdd(i) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 10, "filters are sized for default count of keys")
+	assert.True(t, d.SeenOrAdd(5000))
+}
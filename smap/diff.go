@@ -0,0 +1,200 @@
+package smap
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// diffKind is kind of difference between maps, reported by diffShards.
+type diffKind int
+
+const (
+	diffAdded diffKind = iota
+	diffRemoved
+	diffChanged
+)
+
+// Diff compares maps and returns entries of b, missing in a (added), entries of a, missing in b (removed),
+// and entries of b, which values differ from values in a (changed). Result maps have the same shards layout as a.
+// See DiffFunc for details.
+func Diff[K comparable, V comparable](ctx context.Context, a, b GenericComparable[K, V], workers int) (added, removed, changed Generic[K, V], err error) {
+	return DiffFunc(ctx, a.Generic, b.Generic, workers, func(x, y V) bool {
+		return x == y
+	})
+}
+
+// DiffFunc compares maps with equal func, like Diff does. Shards are processed concurrently from workers goroutines.
+// When maps share shards layout (the same shards count and detector), shard is compared entirely under its locks,
+// otherwise keys are looked up in the other map one by one. Only one shard lock is held at a time,
+// so result does not correspond to any consistent snapshot, if maps are modified concurrently.
+// Returns ctx error and empty maps if ctx is done before all shards are processed.
+func DiffFunc[K comparable, V any](ctx context.Context, a, b Generic[K, V], workers int, equal func(x, y V) bool) (added, removed, changed Generic[K, V], err error) {
+	added, removed, changed = a.emptyCopy(), a.emptyCopy(), a.emptyCopy()
+	err = diffShards(ctx, a, b, workers, equal, func(kind diffKind, key K, value V) bool {
+		switch kind {
+		case diffAdded:
+			added.Store(key, value)
+		case diffRemoved:
+			removed.Store(key, value)
+		case diffChanged:
+			changed.Store(key, value)
+		}
+		return true
+	})
+	if err != nil {
+		return a.emptyCopy(), a.emptyCopy(), a.emptyCopy(), err
+	}
+	return added, removed, changed, nil
+}
+
+// Equal reports whether maps contain the same keys with equal values. See DiffFunc for details.
+func Equal[K comparable, V comparable](ctx context.Context, a, b GenericComparable[K, V], workers int) (bool, error) {
+	return EqualFunc(ctx, a.Generic, b.Generic, workers, func(x, y V) bool {
+		return x == y
+	})
+}
+
+// EqualFunc reports whether maps contain the same keys with values, equal according to equal func.
+// Comparison stops on the first difference. See DiffFunc for details.
+func EqualFunc[K comparable, V any](ctx context.Context, a, b Generic[K, V], workers int, equal func(x, y V) bool) (bool, error) {
+	var differs int32
+	err := diffShards(ctx, a, b, workers, equal, func(diffKind, K, V) bool {
+		atomic.StoreInt32(&differs, 1)
+		return false
+	})
+	if err != nil {
+		return false, err
+	}
+	return atomic.LoadInt32(&differs) == 0, nil
+}
+
+// Merge stores all entries of src to dst. If key is present in both maps, conflict result is stored.
+// conflict is called under dst shard write lock, so it should not call methods of dst.
+// Shards of src are processed concurrently from workers goroutines: shard entries are copied under read lock,
+// and then are stored to dst under single shard write lock, if maps share shards layout, or one by one otherwise.
+// If ctx is done, returns ctx error, some entries could be already merged in that case.
+func Merge[K comparable, V any](ctx context.Context, dst, src Generic[K, V], workers int, conflict func(key K, old, new V) V) error {
+	return parallelShards(ctx, src.ShardsCount(), workers, func(id int, stopped func() bool) bool {
+		entries := src.shardEntries(id)
+
+		if id < dst.ShardsCount() {
+			dst.locks[id].Lock()
+			for key, value := range entries {
+				if dst.shardDetector(key) != id {
+					continue
+				}
+				if old, ok := dst.shards[id][key]; ok {
+					value = conflict(key, old, value)
+				} else {
+					dst.storing(id, key)
+				}
+				dst.shards[id][key] = value
+				delete(entries, key)
+			}
+			dst.locks[id].Unlock()
+		}
+
+		for key, value := range entries {
+			if stopped() {
+				break
+			}
+			value := value
+			dst.WithValue(key, func(v *V, exists bool) bool {
+				if exists {
+					*v = conflict(key, *v, value)
+				} else {
+					*v = value
+				}
+				return true
+			})
+		}
+		return true
+	})
+}
+
+// diffShards calls report for each difference between maps, until report returns false.
+// Each key is reported once: shard of a is copied under its read lock, then matching shard of b is compared under
+// its read lock, and keys, which belong to other shards in the other map layout, are looked up one by one.
+func diffShards[K comparable, V any](ctx context.Context, a, b Generic[K, V], workers int, equal func(x, y V) bool, report func(kind diffKind, key K, value V) bool) error {
+	shardsCount := a.ShardsCount()
+	if b.ShardsCount() > shardsCount {
+		shardsCount = b.ShardsCount()
+	}
+	return parallelShards(ctx, shardsCount, workers, func(id int, stopped func() bool) bool {
+		var aEntries map[K]V
+		if id < a.ShardsCount() {
+			aEntries = a.shardEntries(id)
+		}
+
+		// keys of b shard, which belong to other shard of a
+		var pending []Entry[K, V]
+		if id < b.ShardsCount() {
+			b.locks[id].RLock()
+			for key, bValue := range b.shards[id] {
+				if a.shardDetector(key) != id {
+					pending = append(pending, Entry[K, V]{Key: key, Value: bValue})
+					continue
+				}
+				aValue, ok := aEntries[key]
+				delete(aEntries, key)
+				if ok && equal(aValue, bValue) {
+					continue
+				}
+				kind := diffChanged
+				if !ok {
+					kind = diffAdded
+				}
+				if !report(kind, key, bValue) {
+					b.locks[id].RUnlock()
+					return false
+				}
+			}
+			b.locks[id].RUnlock()
+		}
+
+		for _, e := range pending {
+			if stopped() {
+				return true
+			}
+			aValue, ok := a.Load(e.Key)
+			if ok && equal(aValue, e.Value) {
+				continue
+			}
+			kind := diffChanged
+			if !ok {
+				kind = diffAdded
+			}
+			if !report(kind, e.Key, e.Value) {
+				return false
+			}
+		}
+
+		// remaining keys of a shard are not in b shard with the same id
+		for key, aValue := range aEntries {
+			if stopped() {
+				return true
+			}
+			if b.shardDetector(key) != id {
+				// key in other shard of b is reported as changed by its worker, only missing key is reported here
+				if _, ok := b.Load(key); ok {
+					continue
+				}
+			}
+			if !report(diffRemoved, key, aValue) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// shardEntries returns copy of shard entries, taken under shard read lock.
+func (sm Generic[K, V]) shardEntries(id int) map[K]V {
+	sm.locks[id].RLock()
+	defer sm.locks[id].RUnlock()
+	entries := make(map[K]V, len(sm.shards[id]))
+	for key, value := range sm.shards[id] {
+		entries[key] = value
+	}
+	return entries
+}
@@ -0,0 +1,143 @@
+package smap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+func TestDiff(t *testing.T) {
+	layouts := map[string]func() GenericComparable[int, int]{
+		"same layout": func() GenericComparable[int, int] {
+			return NewIntegerComparable[int, int](8, 0)
+		},
+		"other layout": func() GenericComparable[int, int] {
+			return NewGenericComparable[int, int](3, 0, shard.Must(shard.JumpHash[int](3)))
+		},
+	}
+	for name, newB := range layouts {
+		newB := newB
+		t.Run(name, func(t *testing.T) {
+			a := NewIntegerComparable[int, int](8, 0)
+			b := newB()
+			for i := 0; i < 1000; i++ {
+				a.Store(i, i)
+				if i%10 != 0 { // every 10th key is removed
+					b.Store(i, i)
+				}
+			}
+			for i := 1; i < 1000; i += 10 { // keys 1, 11, ... are changed
+				b.Store(i, -i)
+			}
+			for i := 1000; i < 1100; i++ {
+				b.Store(i, i)
+			}
+
+			added, removed, changed, err := Diff(context.Background(), a, b, 4)
+			assert.NoError(t, err)
+			assert.Equal(t, a.ShardsCount(), added.ShardsCount())
+			assert.Equal(t, entriesOf(t, added), rangeEntries(1000, 1100, 1, func(i int) int { return i }))
+			assert.Equal(t, entriesOf(t, removed), rangeEntries(0, 1000, 10, func(i int) int { return i }))
+			assert.Equal(t, entriesOf(t, changed), rangeEntries(1, 1000, 10, func(i int) int { return -i }))
+
+			equal, err := Equal(context.Background(), a, b, 4)
+			assert.NoError(t, err)
+			assert.False(t, equal)
+
+			_, _, _, err = DiffFunc(context.Background(), a.Generic, b.Generic, 4, func(x, y int) bool {
+				return x == y || x == -y
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := NewIntegerComparable[int, int](8, 0)
+	b := NewGenericComparable[int, int](4, 0, shard.Must(shard.Fibonacci[int](4)))
+	equal, err := Equal(context.Background(), a, b, 0)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+
+	for i := 0; i < 1000; i++ {
+		a.Store(i, i)
+		b.Store(i, i)
+	}
+	equal, err = Equal(context.Background(), a, b, 0)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+
+	b.Store(500, 0)
+	equal, err = Equal(context.Background(), a, b, 0)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+
+	equal, err = EqualFunc(context.Background(), a.Generic, b.Generic, 0, func(x, y int) bool {
+		return x == y || y == 0
+	})
+	assert.NoError(t, err)
+	assert.True(t, equal)
+
+	b.Delete(500)
+	equal, err = Equal(context.Background(), a, b, 0)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Equal(ctx, a, b, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMerge(t *testing.T) {
+	for name, src := range map[string]Generic[int, int]{
+		"same layout":  NewInteger[int, int](8, 0),
+		"other layout": NewGeneric[int, int](5, 0, shard.Must(shard.JumpHash[int](5))),
+	} {
+		src := src
+		t.Run(name, func(t *testing.T) {
+			dst := NewInteger[int, int](8, 0)
+			for i := 0; i < 1000; i++ {
+				dst.Store(i, i)
+				src.Store(i+500, 1)
+			}
+			err := Merge(context.Background(), dst, src, 4, func(key int, old, new int) int {
+				return old + new
+			})
+			assert.NoError(t, err)
+			for i := 0; i < 1500; i++ {
+				v, ok := dst.Load(i)
+				assert.True(t, ok)
+				switch {
+				case i < 500:
+					assert.Equal(t, i, v)
+				case i < 1000:
+					assert.Equal(t, i+1, v)
+				default:
+					assert.Equal(t, 1, v)
+				}
+			}
+		})
+	}
+}
+
+func entriesOf(t *testing.T, m Generic[int, int]) map[int]int {
+	t.Helper()
+	result := make(map[int]int)
+	m.Range(func(k, v int) bool {
+		result[k] = v
+		return true
+	})
+	return result
+}
+
+func rangeEntries(from, to, step int, value func(int) int) map[int]int {
+	result := make(map[int]int)
+	for i := from; i < to; i += step {
+		result[i] = value(i)
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package smap
+
+import (
+	"reflect"
+
+	"golang.org/x/exp/slices"
+)
+
+// GenericEq stores data in N shards, with rw mutex for each.
+// CompareAndSwap, CompareAndDelete and StoreIfChanged methods use provided equality function,
+// so values are not required to be comparable.
+type GenericEq[K comparable, V any] struct {
+	Generic[K, V]
+	equal func(a, b V) bool
+}
+
+// NewGenericEq creates generic RWLocked Sharded map with custom values equality.
+// shardDetector should be idempotent function. SlicesEqual or DeepEqual could be used as equal.
+func NewGenericEq[K comparable, V any](shardsCount, defaultSize int, shardDetector func(key K) int, equal func(a, b V) bool, opts ...Option) GenericEq[K, V] {
+	return GenericEq[K, V]{
+		Generic: NewGeneric[K, V](shardsCount, defaultSize, shardDetector, opts...),
+		equal:   equal,
+	}
+}
+
+// CompareAndSwap executes the compare-and-swap operation for the Key & Value pair.
+// If and only if key exists, and value for key equals old, value will be changed to new.
+// Otherwise, returns current value.
+// The ok result indicates whether value was changed to new in the map.
+func (sm GenericEq[K, V]) CompareAndSwap(key K, old, new V) (V, bool) {
+	shardID := sm.shardDetector(key)
+	sm.locks[shardID].Lock()
+	defer sm.locks[shardID].Unlock()
+	current, ok := sm.shards[shardID][key]
+	if !ok || !sm.equal(current, old) {
+		sm.locks[shardID].countOp(statLoad)
+		return current, false
+	}
+	sm.locks[shardID].countOp(statStore)
+	sm.shards[shardID][key] = new
+	return new, true
+}
+
+// CompareAndDelete deletes the value for a key, if and only if key exists, and value for key equals old.
+// Returns true if value was deleted.
+func (sm GenericEq[K, V]) CompareAndDelete(key K, old V) bool {
+	shardID := sm.shardDetector(key)
+	sm.locks[shardID].Lock()
+	defer sm.locks[shardID].Unlock()
+	current, ok := sm.shards[shardID][key]
+	if !ok || !sm.equal(current, old) {
+		sm.locks[shardID].countOp(statLoad)
+		return false
+	}
+	sm.locks[shardID].countOp(statDelete)
+	delete(sm.shards[shardID], key)
+	sm.removed(shardID, key)
+	sm.deleted(shardID, 1)
+	return true
+}
+
+// StoreIfChanged sets the value for a key, if key is missing, or its value differs from the given one.
+// Returns true if value was stored.
+func (sm GenericEq[K, V]) StoreIfChanged(key K, value V) bool {
+	shardID := sm.shardDetector(key)
+	sm.locks[shardID].RLock()
+	current, ok := sm.shards[shardID][key]
+	sm.locks[shardID].RUnlock()
+	if ok && sm.equal(current, value) {
+		sm.locks[shardID].countOp(statLoad)
+		return false
+	}
+
+	sm.locks[shardID].Lock()
+	defer sm.locks[shardID].Unlock()
+	if current, ok = sm.shards[shardID][key]; ok && sm.equal(current, value) {
+		sm.locks[shardID].countOp(statLoad)
+		return false
+	}
+	sm.locks[shardID].countOp(statStore)
+	sm.storing(shardID, key)
+	sm.shards[shardID][key] = value
+	return true
+}
+
+// SlicesEqual reports whether slices have the same length and equal elements. Nil and empty slices are equal.
+func SlicesEqual[E comparable](a, b []E) bool {
+	return slices.Equal(a, b)
+}
+
+// DeepEqual reports whether values are deeply equal, using reflect.DeepEqual.
+func DeepEqual[V any](a, b V) bool {
+	return reflect.DeepEqual(a, b)
+}
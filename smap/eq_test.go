@@ -0,0 +1,54 @@
+package smap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+func TestGenericEq_CompareAndSwap(t *testing.T) {
+	m := NewGenericEq[int, []string](8, 128, shard.Must(shard.Modulo[int](8)), SlicesEqual[string])
+	val, ok := m.CompareAndSwap(1, nil, []string{"a"}) // no value with key 1 => no change
+	assert.False(t, ok)
+	assert.Nil(t, val)
+	_, ok = m.Load(1)
+	assert.False(t, ok)
+
+	m.Store(1, []string{"a", "b"})
+	val, ok = m.CompareAndSwap(1, []string{"a"}, []string{"c"}) // value differs => no change
+	assert.False(t, ok)
+	assert.Equal(t, []string{"a", "b"}, val)
+
+	val, ok = m.CompareAndSwap(1, []string{"a", "b"}, []string{"c"}) // equal, but other slice => change
+	assert.True(t, ok)
+	assert.Equal(t, []string{"c"}, val)
+	val, _ = m.Load(1)
+	assert.Equal(t, []string{"c"}, val)
+}
+
+func TestGenericEq_CompareAndDelete(t *testing.T) {
+	type config struct {
+		Hosts []string
+		Port  int
+	}
+	m := NewGenericEq[string, config](8, 128, shard.Must(shard.FNV(8)), DeepEqual[config])
+	m.Store("db", config{Hosts: []string{"a", "b"}, Port: 5432})
+
+	assert.False(t, m.CompareAndDelete("db", config{Hosts: []string{"a"}, Port: 5432}))
+	assert.False(t, m.CompareAndDelete("cache", config{}))
+	assert.True(t, m.CompareAndDelete("db", config{Hosts: []string{"a", "b"}, Port: 5432}))
+	_, ok := m.Load("db")
+	assert.False(t, ok)
+}
+
+func TestGenericEq_StoreIfChanged(t *testing.T) {
+	m := NewGenericEq[int, []int](8, 128, shard.Must(shard.Modulo[int](8)), SlicesEqual[int])
+	assert.True(t, m.StoreIfChanged(1, nil)) // missing key is stored
+	assert.False(t, m.StoreIfChanged(1, []int{}))
+	assert.True(t, m.StoreIfChanged(1, []int{1, 2}))
+	assert.False(t, m.StoreIfChanged(1, []int{1, 2}))
+	val, _ := m.Load(1)
+	assert.Equal(t, []int{1, 2}, val)
+}
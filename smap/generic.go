@@ -1,28 +1,50 @@
 package smap
 
-import (
-	"runtime"
-	"sync"
-)
+import "runtime"
 
-// Generic stores data in N shards, with rw mutex for each.
+// Generic stores data in N shards, with rw mutex for each (lock strategy could be changed with WithLock option).
 type Generic[K comparable, V any] struct {
 	shards        []map[K]V
-	locks         []sync.RWMutex
+	locks         []shardLock
 	shardDetector func(key K) int
+
+	// deletions counts deletions in each shard since last compaction, nil if auto compaction is disabled.
+	deletions       []int
+	compactionRatio float64
+
+	// blooms keeps Bloom filter for each shard, nil if filter is disabled.
+	blooms    []bloomFilter
+	bloomHash func(key K) uint64
+
+	// options are kept, so maps derived from sm are configured the same way.
+	options options
 }
 
 // NewGeneric creates generic RWLocked Sharded map.
 // shardDetector should be idempotent function.
-func NewGeneric[K comparable, V any](shardsCount, defaultSize int, shardDetector func(key K) int) Generic[K, V] {
+func NewGeneric[K comparable, V any](shardsCount, defaultSize int, shardDetector func(key K) int, opts ...Option) Generic[K, V] {
+	return newGeneric[K, V](shardsCount, defaultSize, shardDetector, applyOptions(opts))
+}
+
+func newGeneric[K comparable, V any](shardsCount, defaultSize int, shardDetector func(key K) int, o options) Generic[K, V] {
 	sm := Generic[K, V]{
-		shards:        make([]map[K]V, shardsCount),
-		locks:         make([]sync.RWMutex, shardsCount),
-		shardDetector: shardDetector,
+		shards:          make([]map[K]V, shardsCount),
+		locks:           newShardLocks(shardsCount, o.lockKind),
+		shardDetector:   shardDetector,
+		compactionRatio: o.compactionRatio,
+		options:         o,
 	}
 	for i := 0; i < shardsCount; i++ {
 		sm.shards[i] = make(map[K]V, defaultSize)
-		sm.locks[i] = sync.RWMutex{}
+	}
+	if o.compactionRatio > 0 {
+		sm.deletions = make([]int, shardsCount)
+	}
+	if o.bloomHash != nil {
+		sm.bloomHash, sm.blooms = newBloomFilters[K](shardsCount, o)
+	}
+	if o.stats {
+		enableLockStats(sm.locks)
 	}
 	return sm
 }
@@ -31,6 +53,11 @@ func NewGeneric[K comparable, V any](shardsCount, defaultSize int, shardDetector
 // The ok result indicates whether value was found in the map.
 func (sm Generic[K, V]) Load(key K) (V, bool) {
 	shardID := sm.shardDetector(key)
+	sm.locks[shardID].countOp(statLoad)
+	if !sm.mayContain(shardID, key) {
+		var zero V
+		return zero, false
+	}
 	sm.locks[shardID].RLock()
 	value, ok := sm.shards[shardID][key]
 	sm.locks[shardID].RUnlock()
@@ -40,7 +67,9 @@ func (sm Generic[K, V]) Load(key K) (V, bool) {
 // Store sets the value for a key.
 func (sm Generic[K, V]) Store(key K, value V) {
 	shardID := sm.shardDetector(key)
+	sm.locks[shardID].countOp(statStore)
 	sm.locks[shardID].Lock()
+	sm.storing(shardID, key)
 	sm.shards[shardID][key] = value
 	sm.locks[shardID].Unlock()
 }
@@ -49,10 +78,17 @@ func (sm Generic[K, V]) Store(key K, value V) {
 // The loaded result reports whether the key was present.
 func (sm Generic[K, V]) LoadAndDelete(key K) (V, bool) {
 	shardID := sm.shardDetector(key)
+	sm.locks[shardID].countOp(statDelete)
+	if !sm.mayContain(shardID, key) {
+		var zero V
+		return zero, false
+	}
 	sm.locks[shardID].Lock()
 	value, ok := sm.shards[shardID][key]
 	if ok {
 		delete(sm.shards[shardID], key)
+		sm.removed(shardID, key)
+		sm.deleted(shardID, 1)
 	}
 	sm.locks[shardID].Unlock()
 	return value, ok
@@ -64,28 +100,45 @@ func (sm Generic[K, V]) LoadAndDelete(key K) (V, bool) {
 // The loaded result is true if the value was loaded, false if stored.
 func (sm Generic[K, V]) LoadOrCreate(key K, generator func() V) (V, bool) {
 	shardID := sm.shardDetector(key)
-	sm.locks[shardID].RLock()
-	value, ok := sm.shards[shardID][key]
-	sm.locks[shardID].RUnlock()
-	if ok {
-		return value, ok
+	if sm.mayContain(shardID, key) {
+		sm.locks[shardID].RLock()
+		value, ok := sm.shards[shardID][key]
+		sm.locks[shardID].RUnlock()
+		if ok {
+			sm.locks[shardID].countOp(statLoad)
+			return value, ok
+		}
 	}
 
 	sm.locks[shardID].Lock()
-	value, ok = sm.shards[shardID][key]
+	value, ok := sm.shards[shardID][key]
 	if !ok {
 		value = generator()
+		sm.storing(shardID, key)
 		sm.shards[shardID][key] = value
 	}
 	sm.locks[shardID].Unlock()
+	if ok {
+		sm.locks[shardID].countOp(statLoad)
+	} else {
+		sm.locks[shardID].countOp(statStore)
+	}
 	return value, ok
 }
 
 // Delete deletes the value for a key.
 func (sm Generic[K, V]) Delete(key K) {
 	shardID := sm.shardDetector(key)
+	sm.locks[shardID].countOp(statDelete)
+	if !sm.mayContain(shardID, key) {
+		return
+	}
 	sm.locks[shardID].Lock()
-	delete(sm.shards[shardID], key)
+	if _, ok := sm.shards[shardID][key]; ok {
+		delete(sm.shards[shardID], key)
+		sm.removed(shardID, key)
+		sm.deleted(shardID, 1)
+	}
 	sm.locks[shardID].Unlock()
 }
 
@@ -158,21 +211,27 @@ func (sm Generic[K, V]) RUnlockShard(id int) {
 }
 
 // UnblockedGet returns value, without locks.
-// Use with caution, only when lock or rlock were taken for shard.
+// Use with caution, only when lock or rlock were taken for shard. Build with smapdebug tag to check it.
 func (sm Generic[K, V]) UnblockedGet(key K) (V, bool) {
-	value, ok := sm.shards[sm.shardDetector(key)][key]
+	shardID := sm.shardDetector(key)
+	sm.checkLocked("UnblockedGet", shardID, false)
+	value, ok := sm.shards[shardID][key]
 	return value, ok
 }
 
 // UnblockedSet sets value, without locks.
-// Use with caution, only when lock were taken for shard.
+// Use with caution, only when lock were taken for shard. Build with smapdebug tag to check it.
 func (sm Generic[K, V]) UnblockedSet(key K, value V) {
-	sm.shards[sm.shardDetector(key)][key] = value
+	shardID := sm.shardDetector(key)
+	sm.checkLocked("UnblockedSet", shardID, true)
+	sm.storing(shardID, key)
+	sm.shards[shardID][key] = value
 }
 
 // UnblockedShardRange calls cb sequentially for each key and value present in the maps shard.
-// Use with caution, only when lock or rlock were taken for shard.
+// Use with caution, only when lock or rlock were taken for shard. Build with smapdebug tag to check it.
 func (sm Generic[K, V]) UnblockedShardRange(shardID int, cb func(key K, value V) bool) {
+	sm.checkLocked("UnblockedShardRange", shardID, false)
 	for key, value := range sm.shards[shardID] {
 		if !cb(key, value) {
 			break
@@ -5,15 +5,27 @@ import (
 )
 
 // NewInteger creates sharded rwlock maps with shard detection based on key division to shards count modulo.
-func NewInteger[K constraints.Integer, V any](shardsCount, defaultSize int) Generic[K, V] {
-	return NewGeneric[K, V](shardsCount, defaultSize, func(key K) int {
-		return int(key) % shardsCount
-	})
+// Other shard detector could be set with WithIntegerDetector option.
+func NewInteger[K constraints.Integer, V any](shardsCount, defaultSize int, opts ...Option) Generic[K, V] {
+	return NewGeneric[K, V](shardsCount, defaultSize, integerDetector[K](shardsCount, opts), opts...)
 }
 
 // NewIntegerComparable creates sharded rwlock maps with comparable values.
-func NewIntegerComparable[K constraints.Integer, V comparable](shardsCount, defaultSize int) GenericComparable[K, V] {
-	return NewGenericComparable[K, V](shardsCount, defaultSize, func(key K) int {
-		return int(key) % shardsCount
-	})
+// Other shard detector could be set with WithIntegerDetector option.
+func NewIntegerComparable[K constraints.Integer, V comparable](shardsCount, defaultSize int, opts ...Option) GenericComparable[K, V] {
+	return NewGenericComparable[K, V](shardsCount, defaultSize, integerDetector[K](shardsCount, opts), opts...)
+}
+
+// integerDetector returns detector, set with WithIntegerDetector option, or modulo detector by default.
+// Keys are converted to uint64, so negative keys are handled correctly.
+func integerDetector[K constraints.Integer](shardsCount int, opts []Option) func(key K) int {
+	if detector := applyOptions(opts).integerDetector; detector != nil {
+		return func(key K) int {
+			return detector(uint64(key))
+		}
+	}
+	count := uint64(shardsCount)
+	return func(key K) int {
+		return int(uint64(key) % count)
+	}
 }
@@ -156,8 +156,35 @@ func BenchmarkLockMap_ConcurrentGetSet1(b *testing.B) {
 	benchmarkLockMapConcurrentGetSet(b, 99) // 99% reads, 1% writes
 }
 
-func benchmarkIntegerShardedMapConcurrentGetSet(b *testing.B, ratio int) {
-	sm := smap.NewIntegerComparable[uint16, uint64](smap.HeuristicOptimalDistribution(math.MaxUint16))
+// BenchmarkIntegerShardedMap_LockKinds runs GetSet benchmarks for each shard lock strategy.
+func BenchmarkIntegerShardedMap_LockKinds(b *testing.B) {
+	for _, lock := range []struct {
+		name string
+		kind smap.LockKind
+	}{
+		{"RWMutex", smap.LockRWMutex},
+		{"Mutex", smap.LockMutex},
+		{"Spin", smap.LockSpin},
+		{"BRAVO", smap.LockBRAVO},
+	} {
+		for _, ratio := range []struct {
+			name  string
+			ratio int
+		}{
+			{"GetSet50", 1},
+			{"GetSet5", 19},
+			{"GetSet1", 99},
+		} {
+			b.Run(lock.name+"/"+ratio.name, func(b *testing.B) {
+				benchmarkIntegerShardedMapConcurrentGetSet(b, ratio.ratio, smap.WithLock(lock.kind))
+			})
+		}
+	}
+}
+
+func benchmarkIntegerShardedMapConcurrentGetSet(b *testing.B, ratio int, opts ...smap.Option) {
+	shardsCount, shardSize := smap.HeuristicOptimalDistribution(math.MaxUint16)
+	sm := smap.NewIntegerComparable[uint16, uint64](shardsCount, shardSize, opts...)
 	b.ReportAllocs()
 	b.ResetTimer()
 
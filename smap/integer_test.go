@@ -1,9 +1,12 @@
 package smap
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
 )
 
 func TestGeneric_Delete(t *testing.T) {
@@ -101,3 +104,30 @@ func TestGenericComparable_CompareAndSwap(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, 23, val)
 }
+
+func TestInteger_NegativeKeys(t *testing.T) {
+	m := NewInteger[int8, int](8, 128)
+	for i := math.MinInt8; i <= math.MaxInt8; i++ {
+		m.Store(int8(i), i)
+	}
+	for i := math.MinInt8; i <= math.MaxInt8; i++ {
+		val, ok := m.Load(int8(i))
+		assert.True(t, ok)
+		assert.Equal(t, i, val)
+		assert.True(t, m.ShardID(int8(i)) >= 0 && m.ShardID(int8(i)) < 8)
+	}
+}
+
+func TestInteger_WithIntegerDetector(t *testing.T) {
+	m := NewIntegerComparable[int, int](16, 128, WithIntegerDetector(shard.Must(shard.Fibonacci[uint64](16))))
+	shards := make(map[int]int)
+	for i := 0; i < 16*1024; i += 16 {
+		m.Store(i, i)
+		shards[m.ShardID(i)]++
+	}
+	assert.Len(t, shards, 16) // keys with stride equal to shards count are spread over all shards
+
+	val, ok := m.Load(32)
+	assert.True(t, ok)
+	assert.Equal(t, 32, val)
+}
@@ -0,0 +1,118 @@
+package smap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// KeyedMutex is reader/writer lock per key. Locks are allocated on demand in sharded map, and are reference counted:
+// lock is deleted, when the last goroutine, holding or waiting for it, releases it, so memory is bounded by count
+// of keys, which are locked or awaited at the moment. Unlike shard locks, locks of different keys in the same shard
+// do not block each other, shard lock is held only while reference count is updated.
+type KeyedMutex[K comparable] struct {
+	m Generic[K, *keyedLock]
+}
+
+// keyedLock is lock of single key. refs is count of goroutines holding or waiting for the lock,
+// it's guarded by shard lock.
+type keyedLock struct {
+	rw   sync.RWMutex
+	refs int
+}
+
+// NewKeyedMutex creates keyed mutex, with locks distributed to shardsCount shards by shardDetector.
+func NewKeyedMutex[K comparable](shardsCount int, shardDetector func(key K) int, opts ...Option) KeyedMutex[K] {
+	return KeyedMutex[K]{m: NewGeneric[K, *keyedLock](shardsCount, 0, shardDetector, opts...)}
+}
+
+// Lock locks key for writing. If key is locked already, Lock blocks until it's unlocked.
+func (km KeyedMutex[K]) Lock(key K) {
+	km.acquire(key).rw.Lock()
+}
+
+// Unlock unlocks key for writing. It panics, if key is not locked.
+func (km KeyedMutex[K]) Unlock(key K) {
+	km.release(key, "Unlock", (*sync.RWMutex).Unlock)
+}
+
+// RLock locks key for reading. Several readers could hold the lock, while there are no writers.
+func (km KeyedMutex[K]) RLock(key K) {
+	km.acquire(key).rw.RLock()
+}
+
+// RUnlock unlocks key for reading. It panics, if key is not locked.
+func (km KeyedMutex[K]) RUnlock(key K) {
+	km.release(key, "RUnlock", (*sync.RWMutex).RUnlock)
+}
+
+// TryLock tries to lock key for writing and reports whether it succeeded.
+func (km KeyedMutex[K]) TryLock(key K) bool {
+	shardID := km.m.shardDetector(key)
+	km.m.locks[shardID].Lock()
+	defer km.m.locks[shardID].Unlock()
+	l, ok := km.m.shards[shardID][key]
+	if ok {
+		if !l.rw.TryLock() {
+			return false
+		}
+	} else {
+		l = &keyedLock{}
+		l.rw.Lock() // new lock is free
+		km.m.storing(shardID, key)
+		km.m.shards[shardID][key] = l
+	}
+	l.refs++
+	return true
+}
+
+// LockContext locks key for writing, or returns ctx error if ctx is done before lock is acquired.
+// Like Generic.LockShardContext, lock is acquired with TryLock and backoff.
+func (km KeyedMutex[K]) LockContext(ctx context.Context, key K) error {
+	l := km.acquire(key)
+	if err := acquireContext(ctx, l.rw.TryLock); err != nil {
+		km.release(key, "LockContext", nil)
+		return err
+	}
+	return nil
+}
+
+// Len returns count of allocated key locks.
+func (km KeyedMutex[K]) Len() int {
+	return km.m.Stats().Len()
+}
+
+// acquire returns lock of the key, allocating it if needed, and increments its reference count.
+func (km KeyedMutex[K]) acquire(key K) *keyedLock {
+	shardID := km.m.shardDetector(key)
+	km.m.locks[shardID].Lock()
+	l, ok := km.m.shards[shardID][key]
+	if !ok {
+		l = &keyedLock{}
+		km.m.storing(shardID, key)
+		km.m.shards[shardID][key] = l
+	}
+	l.refs++
+	km.m.locks[shardID].Unlock()
+	return l
+}
+
+// release calls unlock for lock of the key, if it's not nil, decrements reference count, and deletes unused lock.
+func (km KeyedMutex[K]) release(key K, op string, unlock func(*sync.RWMutex)) {
+	shardID := km.m.shardDetector(key)
+	km.m.locks[shardID].Lock()
+	defer km.m.locks[shardID].Unlock()
+	l, ok := km.m.shards[shardID][key]
+	if !ok {
+		panic(fmt.Sprintf("smap: KeyedMutex.%s of key %v, which is not locked", op, key))
+	}
+	if unlock != nil {
+		unlock(&l.rw)
+	}
+	l.refs--
+	if l.refs == 0 {
+		delete(km.m.shards[shardID], key)
+		km.m.removed(shardID, key)
+		km.m.deleted(shardID, 1)
+	}
+}
@@ -0,0 +1,87 @@
+package smap
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+func TestKeyedMutex_Exclusion(t *testing.T) {
+	km := NewKeyedMutex[int](4, shard.Must(shard.Modulo[int](4)))
+	counters := make([]int, 8)
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := (g + i) % len(counters)
+				if i%4 == 0 {
+					km.RLock(key)
+					_ = counters[key]
+					km.RUnlock(key)
+					continue
+				}
+				km.Lock(key)
+				counters[key]++
+				km.Unlock(key)
+			}
+		}(g)
+	}
+	wg.Wait()
+	total := 0
+	for _, c := range counters {
+		total += c
+	}
+	assert.Equal(t, 16*750, total)
+	assert.Zero(t, km.Len(), "unused locks are freed")
+}
+
+func TestKeyedMutex_TryLock(t *testing.T) {
+	km := NewKeyedMutex[int](4, shard.Must(shard.Modulo[int](4)))
+	assert.True(t, km.TryLock(1))
+	assert.False(t, km.TryLock(1))
+	assert.True(t, km.TryLock(5), "keys of the same shard do not block each other")
+	assert.Equal(t, 2, km.Len())
+	km.Unlock(1)
+	km.Unlock(5)
+	assert.Zero(t, km.Len())
+
+	km.RLock(2)
+	km.RLock(2)
+	assert.False(t, km.TryLock(2))
+	km.RUnlock(2)
+	km.RUnlock(2)
+	assert.True(t, km.TryLock(2))
+	km.Unlock(2)
+	assert.Zero(t, km.Len())
+
+	assert.PanicsWithValue(t, "smap: KeyedMutex.Unlock of key 3, which is not locked", func() {
+		km.Unlock(3)
+	})
+}
+
+func TestKeyedMutex_LockContext(t *testing.T) {
+	km := NewKeyedMutex[int](4, shard.Must(shard.Modulo[int](4)))
+	assert.NoError(t, km.LockContext(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, km.LockContext(ctx, 1), context.DeadlineExceeded)
+	assert.Equal(t, 1, km.Len())
+
+	done := make(chan error)
+	go func() {
+		done <- km.LockContext(context.Background(), 1)
+	}()
+	time.Sleep(time.Millisecond)
+	km.Unlock(1)
+	assert.NoError(t, <-done)
+	km.Unlock(1)
+	assert.Zero(t, km.Len())
+}
@@ -0,0 +1,383 @@
+package smap
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// LockKind is strategy of shard locks.
+type LockKind int
+
+const (
+	// LockRWMutex uses sync.RWMutex, it's the default.
+	LockRWMutex LockKind = iota
+	// LockMutex uses sync.Mutex, readers are exclusive too. It's cheaper for write-heavy workloads.
+	LockMutex
+	// LockSpin spins with TryLock for a while, and then parks on sync.Mutex. Readers are exclusive.
+	// It fits short critical sections with low contention.
+	LockSpin
+	// LockBRAVO is reader-biased lock: while there are no writers, readers only increment one of striped
+	// counters, without touching shared lock word. Writer revokes the bias and waits for readers to drain,
+	// so writes are more expensive. Counters take about 1KB per shard, e.g. ~1.5MB for 1536 shards,
+	// which is HeuristicOptimalShardsCount with GOMAXPROCS=8.
+	LockBRAVO
+)
+
+// WithLock sets lock strategy for shards of Generic maps.
+func WithLock(kind LockKind) Option {
+	return func(o *options) {
+		o.lockKind = kind
+	}
+}
+
+// locker is custom shard lock implementation.
+type locker interface {
+	Lock()
+	Unlock()
+	TryLock() bool
+	RLock()
+	RUnlock()
+	TryRLock() bool
+}
+
+// shardLock is bare sync.RWMutex, unless custom lock strategy, lock stats or checked mode are enabled.
+// Their state is kept behind the pointer, so the default lock is sync.RWMutex plus a pointer,
+// and its fast path costs a single nil check.
+type shardLock struct {
+	rw  sync.RWMutex
+	ext *shardLockExt
+}
+
+// shardLockExt is optional state of shardLock. Lock owners are tracked in checked mode only,
+// acquisitions are counted only if WithStats option is set.
+type shardLockExt struct {
+	owners lockOwners
+	custom locker
+	stats  *lockStats
+}
+
+func newShardLocks(shardsCount int, kind LockKind) []shardLock {
+	locks := make([]shardLock, shardsCount)
+	for i := range locks {
+		var custom locker
+		switch kind {
+		case LockMutex:
+			custom = &mutexLock{}
+		case LockSpin:
+			custom = &spinLock{}
+		case LockBRAVO:
+			custom = &bravoLock{}
+		}
+		if custom != nil || checkedMode {
+			locks[i].extension(i).custom = custom
+		}
+	}
+	return locks
+}
+
+// extension returns optional state of the lock with given shard id, allocating it if needed.
+func (l *shardLock) extension(shardID int) *shardLockExt {
+	if l.ext == nil {
+		l.ext = &shardLockExt{}
+		l.ext.owners.init(shardID)
+	}
+	return l.ext
+}
+
+func (l *shardLock) Lock() {
+	e := l.ext
+	if e == nil {
+		l.rw.Lock()
+		return
+	}
+	// misuse is checked before TryLock, which would fail silently
+	e.owners.beforeLock()
+	if e.stats != nil {
+		// TryLock counts acquisition or contended attempt
+		if l.TryLock() {
+			return
+		}
+		atomic.AddInt64(&e.stats.writes, 1)
+	}
+	if e.custom != nil {
+		e.custom.Lock()
+	} else {
+		l.rw.Lock()
+	}
+	e.owners.locked()
+}
+
+func (l *shardLock) Unlock() {
+	e := l.ext
+	if e == nil {
+		l.rw.Unlock()
+		return
+	}
+	e.owners.unlocked()
+	if e.custom != nil {
+		e.custom.Unlock()
+		return
+	}
+	l.rw.Unlock()
+}
+
+func (l *shardLock) TryLock() bool {
+	e := l.ext
+	if e == nil {
+		return l.rw.TryLock()
+	}
+	var ok bool
+	if e.custom != nil {
+		ok = e.custom.TryLock()
+	} else {
+		ok = l.rw.TryLock()
+	}
+	if ok {
+		e.owners.locked()
+	}
+	if e.stats != nil {
+		e.stats.count(&e.stats.writes, &e.stats.contendedWrites, ok)
+	}
+	return ok
+}
+
+func (l *shardLock) RLock() {
+	e := l.ext
+	if e == nil {
+		l.rw.RLock()
+		return
+	}
+	e.owners.beforeRLock()
+	if e.stats != nil {
+		if l.TryRLock() {
+			return
+		}
+		atomic.AddInt64(&e.stats.reads, 1)
+	}
+	l.rlock(e)
+}
+
+// uncountedRLock takes read lock without counting it in lock stats.
+func (l *shardLock) uncountedRLock() {
+	e := l.ext
+	if e == nil {
+		l.rw.RLock()
+		return
+	}
+	e.owners.beforeRLock()
+	l.rlock(e)
+}
+
+// rlock takes read lock after misuse is checked.
+func (l *shardLock) rlock(e *shardLockExt) {
+	if e.custom != nil {
+		e.custom.RLock()
+	} else {
+		l.rw.RLock()
+	}
+	e.owners.rlocked()
+}
+
+func (l *shardLock) RUnlock() {
+	e := l.ext
+	if e == nil {
+		l.rw.RUnlock()
+		return
+	}
+	e.owners.runlocked()
+	if e.custom != nil {
+		e.custom.RUnlock()
+		return
+	}
+	l.rw.RUnlock()
+}
+
+func (l *shardLock) TryRLock() bool {
+	e := l.ext
+	if e == nil {
+		return l.rw.TryRLock()
+	}
+	var ok bool
+	if e.custom != nil {
+		ok = e.custom.TryRLock()
+	} else {
+		ok = l.rw.TryRLock()
+	}
+	if ok {
+		e.owners.rlocked()
+	}
+	if e.stats != nil {
+		e.stats.count(&e.stats.reads, &e.stats.contendedReads, ok)
+	}
+	return ok
+}
+
+// mutexLock is exclusive lock for both readers and writers.
+type mutexLock struct {
+	sync.Mutex
+}
+
+func (l *mutexLock) RLock()         { l.Lock() }
+func (l *mutexLock) RUnlock()       { l.Unlock() }
+func (l *mutexLock) TryRLock() bool { return l.TryLock() }
+
+// spinLockAttempts is count of TryLock attempts before spinLock parks.
+const spinLockAttempts = 32
+
+// spinLock tries to acquire mutex several times, yielding the processor between attempts,
+// and then parks on it. Readers are exclusive.
+type spinLock struct {
+	sync.Mutex
+}
+
+func (l *spinLock) Lock() {
+	for i := 0; i < spinLockAttempts; i++ {
+		if l.Mutex.TryLock() {
+			return
+		}
+		runtime.Gosched()
+	}
+	l.Mutex.Lock()
+}
+
+func (l *spinLock) RLock()         { l.Lock() }
+func (l *spinLock) RUnlock()       { l.Unlock() }
+func (l *spinLock) TryRLock() bool { return l.TryLock() }
+
+const (
+	// bravoSlots is count of striped reader counters.
+	bravoSlots = 16
+	// bravoInhibitMultiplier is how many times longer than the last bias revocation readers stay unbiased.
+	bravoInhibitMultiplier = 9
+)
+
+// bravoLock is BRAVO-style reader-biased lock (Dice, Kogan, "BRAVO: Biased Locking for Reader-Writer Locks").
+// While bias is set, readers increment striped counter, chosen by goroutine stack address, and check bias again.
+// Writer takes underlying lock, revokes bias and waits until counters sum is zero. Readers, which found bias
+// revoked, pass through underlying read lock, and count themselves in counters as well, so RUnlock
+// does not need to know how lock was taken. Bias is restored by readers after inhibit period, which is
+// proportional to the revocation time, so write-heavy workloads fall back to plain RWMutex.
+type bravoLock struct {
+	// 64-bit fields go first for alignment on 32-bit platforms
+	inhibitUntil int64
+	readers      [bravoSlots]struct {
+		count int64
+		_     [56]byte // counters are on separate cache lines
+	}
+	bias int32
+	rw   sync.RWMutex
+}
+
+func (l *bravoLock) RLock() {
+	slot := bravoSlot()
+	if l.fastRLock(slot) {
+		return
+	}
+	l.rw.RLock()
+	l.slowRLocked(slot)
+}
+
+func (l *bravoLock) TryRLock() bool {
+	slot := bravoSlot()
+	if l.fastRLock(slot) {
+		return true
+	}
+	if !l.rw.TryRLock() {
+		return false
+	}
+	l.slowRLocked(slot)
+	return true
+}
+
+func (l *bravoLock) RUnlock() {
+	// reader could be moved to other stack, and counters are summed by writer, so any slot could be decremented
+	atomic.AddInt64(&l.readers[bravoSlot()].count, -1)
+}
+
+func (l *bravoLock) Lock() {
+	l.rw.Lock()
+	l.revoke()
+}
+
+func (l *bravoLock) TryLock() bool {
+	if !l.rw.TryLock() {
+		return false
+	}
+	biased := atomic.LoadInt32(&l.bias) == 1
+	atomic.StoreInt32(&l.bias, 0)
+	if l.readersCount() != 0 {
+		if biased {
+			atomic.StoreInt32(&l.bias, 1)
+		}
+		l.rw.Unlock()
+		return false
+	}
+	return true
+}
+
+func (l *bravoLock) Unlock() {
+	l.rw.Unlock()
+}
+
+func (l *bravoLock) fastRLock(slot int) bool {
+	if atomic.LoadInt32(&l.bias) == 0 {
+		return false
+	}
+	atomic.AddInt64(&l.readers[slot].count, 1)
+	if atomic.LoadInt32(&l.bias) == 1 {
+		return true
+	}
+	atomic.AddInt64(&l.readers[slot].count, -1)
+	return false
+}
+
+// slowRLocked counts reader, which holds underlying read lock, releases the lock, and restores bias if inhibit
+// period is over. Writer can't take the lock until counters sum is zero, so reader is still protected.
+func (l *bravoLock) slowRLocked(slot int) {
+	atomic.AddInt64(&l.readers[slot].count, 1)
+	if atomic.LoadInt32(&l.bias) == 0 && time.Now().UnixNano() >= atomic.LoadInt64(&l.inhibitUntil) {
+		atomic.StoreInt32(&l.bias, 1)
+	}
+	l.rw.RUnlock()
+}
+
+// revoke clears bias and waits for readers to drain, should be called under underlying write lock.
+func (l *bravoLock) revoke() {
+	if atomic.LoadInt32(&l.bias) == 1 {
+		start := time.Now()
+		atomic.StoreInt32(&l.bias, 0)
+		l.waitReaders()
+		elapsed := time.Since(start)
+		atomic.StoreInt64(&l.inhibitUntil, time.Now().Add(bravoInhibitMultiplier*elapsed).UnixNano())
+		return
+	}
+	l.waitReaders()
+}
+
+func (l *bravoLock) waitReaders() {
+	for i := 0; l.readersCount() != 0; i++ {
+		if i < spinLockAttempts {
+			runtime.Gosched()
+		} else {
+			time.Sleep(time.Microsecond)
+		}
+	}
+}
+
+func (l *bravoLock) readersCount() int64 {
+	var count int64
+	for i := range l.readers {
+		count += atomic.LoadInt64(&l.readers[i].count)
+	}
+	return count
+}
+
+// bravoSlot returns striped counter index for current goroutine, derived from its stack address.
+func bravoSlot() int {
+	var marker byte
+	addr := uint64(uintptr(unsafe.Pointer(&marker)))
+	return int((addr >> 12) * 0x9e3779b97f4a7c15 >> 60)
+}
@@ -0,0 +1,134 @@
+package smap
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var lockKinds = map[string]LockKind{
+	"RWMutex": LockRWMutex,
+	"Mutex":   LockMutex,
+	"Spin":    LockSpin,
+	"BRAVO":   LockBRAVO,
+}
+
+func TestShardLock_Exclusion(t *testing.T) {
+	for name, kind := range lockKinds {
+		kind := kind
+		t.Run(name, func(t *testing.T) {
+			l := &newShardLocks(1, kind)[0]
+			// writers keep a == b under write lock, readers check it under read lock
+			var a, b int
+			var wg sync.WaitGroup
+			for g := 0; g < 8; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 2000; i++ {
+						if g%2 == 0 {
+							l.Lock()
+							a++
+							b++
+							l.Unlock()
+						} else {
+							l.RLock()
+							equal := a == b
+							l.RUnlock()
+							if !equal {
+								t.Error("reader observed partial write")
+								return
+							}
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+			assert.Equal(t, 8000, a)
+		})
+	}
+}
+
+func TestShardLock_TryLock(t *testing.T) {
+	for name, kind := range lockKinds {
+		kind := kind
+		t.Run(name, func(t *testing.T) {
+			l := &newShardLocks(1, kind)[0]
+			assert.True(t, l.TryLock())
+			assert.False(t, l.TryLock())
+			assert.False(t, l.TryRLock())
+			l.Unlock()
+
+			assert.True(t, l.TryRLock())
+			assert.False(t, l.TryLock(), "writer should not acquire lock held by reader")
+			l.RUnlock()
+			assert.True(t, l.TryLock())
+			l.Unlock()
+		})
+	}
+}
+
+func TestShardLock_DefaultIsBare(t *testing.T) {
+	l := &newShardLocks(1, LockRWMutex)[0]
+	assert.Equal(t, checkedMode, l.ext != nil, "optional state is allocated only in checked mode")
+	assert.Equal(t, unsafe.Sizeof(sync.RWMutex{})+unsafe.Sizeof(uintptr(0)), unsafe.Sizeof(*l))
+
+	assert.NotNil(t, newShardLocks(1, LockBRAVO)[0].ext)
+}
+
+func TestBravoLock_Bias(t *testing.T) {
+	l := &bravoLock{}
+	l.RLock() // first reader takes slow path and sets bias
+	l.RUnlock()
+	assert.Equal(t, int32(1), l.bias)
+
+	l.RLock() // fast path
+	assert.Equal(t, int64(1), l.readersCount())
+	assert.False(t, l.TryLock())
+	assert.Equal(t, int32(1), l.bias, "failed TryLock should restore bias")
+
+	locked := make(chan struct{})
+	go func() {
+		l.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("writer acquired lock held by reader")
+	default:
+	}
+	l.RUnlock()
+	<-locked
+	assert.Equal(t, int32(0), l.bias, "writer should revoke bias")
+	assert.False(t, l.TryRLock())
+	l.Unlock()
+	assert.Equal(t, int64(0), l.readersCount())
+}
+
+func TestGeneric_WithLock(t *testing.T) {
+	for name, kind := range lockKinds {
+		kind := kind
+		t.Run(name, func(t *testing.T) {
+			m := NewInteger[int, int](4, 0, WithLock(kind))
+			var wg sync.WaitGroup
+			for g := 0; g < 4; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < 1000; i++ {
+						m.Store(g*1000+i, i)
+						_, _ = m.Load(g*1000 + i/2)
+					}
+				}(g)
+			}
+			wg.Wait()
+			for i := 0; i < 4000; i++ {
+				v, ok := m.Load(i)
+				assert.True(t, ok)
+				assert.Equal(t, i%1000, v)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package smap
+
+import (
+	"sync"
+)
+
+// Map is common interface of concurrent maps, so implementation could be chosen per workload.
+type Map[K comparable, V any] interface {
+	// Load returns the value stored in the map for a key.
+	// The ok result indicates whether value was found in the map.
+	Load(key K) (V, bool)
+	// Store sets the value for a key.
+	Store(key K, value V)
+	// LoadAndDelete deletes the value for a key, returning the previous value if any.
+	// The loaded result reports whether the key was present.
+	LoadAndDelete(key K) (V, bool)
+	// LoadOrCreate returns the existing value for the key if present.
+	// Otherwise, it calls generator func, stores and returns the generator's result.
+	// The loaded result is true if the value was loaded, false if stored.
+	LoadOrCreate(key K, generator func() V) (V, bool)
+	// Delete deletes the value for a key.
+	Delete(key K)
+	// Range calls cb sequentially for each key and value present in the map.
+	// If cb returns false, range stops the iteration.
+	Range(cb func(K, V) bool)
+}
+
+var (
+	_ Map[int, int] = Generic[int, int]{}
+	_ Map[int, int] = GenericComparable[int, int]{}
+	_ Map[int, int] = SyncMap[int, int]{}
+	_ Map[int, int] = Locked[int, int]{}
+)
+
+// SyncMap is typed adapter for sync.Map.
+type SyncMap[K comparable, V any] struct {
+	m *sync.Map
+}
+
+// FromSyncMap wraps sync.Map. All keys and values stored in m should have K and V types.
+func FromSyncMap[K comparable, V any](m *sync.Map) SyncMap[K, V] {
+	return SyncMap[K, V]{m: m}
+}
+
+// Load returns the value stored in the map for a key.
+// The ok result indicates whether value was found in the map.
+func (sm SyncMap[K, V]) Load(key K) (V, bool) {
+	value, ok := sm.m.Load(key)
+	return typed[V](value, ok)
+}
+
+// Store sets the value for a key.
+func (sm SyncMap[K, V]) Store(key K, value V) {
+	sm.m.Store(key, value)
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (sm SyncMap[K, V]) LoadAndDelete(key K) (V, bool) {
+	value, ok := sm.m.LoadAndDelete(key)
+	return typed[V](value, ok)
+}
+
+// LoadOrCreate returns the existing value for the key if present.
+// Otherwise, it calls generator func, stores and returns the generator's result.
+// Unlike Generic, generator could be called even if value is stored concurrently, its result is dropped then.
+// The loaded result is true if the value was loaded, false if stored.
+func (sm SyncMap[K, V]) LoadOrCreate(key K, generator func() V) (V, bool) {
+	if value, ok := sm.m.Load(key); ok {
+		return value.(V), true
+	}
+	value, ok := sm.m.LoadOrStore(key, generator())
+	return value.(V), ok
+}
+
+// Delete deletes the value for a key.
+func (sm SyncMap[K, V]) Delete(key K) {
+	sm.m.Delete(key)
+}
+
+// Range calls cb sequentially for each key and value present in the map.
+// If cb returns false, range stops the iteration.
+func (sm SyncMap[K, V]) Range(cb func(K, V) bool) {
+	sm.m.Range(func(key, value any) bool {
+		return cb(key.(K), value.(V))
+	})
+}
+
+func typed[V any](value any, ok bool) (V, bool) {
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return value.(V), true
+}
+
+// Locked is map, protected with single RWMutex.
+type Locked[K comparable, V any] struct {
+	lock *sync.RWMutex
+	m    map[K]V
+}
+
+// NewLocked creates map with single RWMutex.
+func NewLocked[K comparable, V any](defaultSize int) Locked[K, V] {
+	return Locked[K, V]{
+		lock: &sync.RWMutex{},
+		m:    make(map[K]V, defaultSize),
+	}
+}
+
+// Load returns the value stored in the map for a key.
+// The ok result indicates whether value was found in the map.
+func (lm Locked[K, V]) Load(key K) (V, bool) {
+	lm.lock.RLock()
+	value, ok := lm.m[key]
+	lm.lock.RUnlock()
+	return value, ok
+}
+
+// Store sets the value for a key.
+func (lm Locked[K, V]) Store(key K, value V) {
+	lm.lock.Lock()
+	lm.m[key] = value
+	lm.lock.Unlock()
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (lm Locked[K, V]) LoadAndDelete(key K) (V, bool) {
+	lm.lock.Lock()
+	value, ok := lm.m[key]
+	if ok {
+		delete(lm.m, key)
+	}
+	lm.lock.Unlock()
+	return value, ok
+}
+
+// LoadOrCreate returns the existing value for the key if present.
+// Otherwise, it calls generator func, stores and returns the generator's result.
+// Generator will not be called if key present.
+// The loaded result is true if the value was loaded, false if stored.
+func (lm Locked[K, V]) LoadOrCreate(key K, generator func() V) (V, bool) {
+	lm.lock.RLock()
+	value, ok := lm.m[key]
+	lm.lock.RUnlock()
+	if ok {
+		return value, ok
+	}
+
+	lm.lock.Lock()
+	value, ok = lm.m[key]
+	if !ok {
+		value = generator()
+		lm.m[key] = value
+	}
+	lm.lock.Unlock()
+	return value, ok
+}
+
+// Delete deletes the value for a key.
+func (lm Locked[K, V]) Delete(key K) {
+	lm.lock.Lock()
+	delete(lm.m, key)
+	lm.lock.Unlock()
+}
+
+// Range calls cb sequentially for each key and value present in the map.
+// If cb returns false, range stops the iteration.
+// Like Generic.Range, lock is not held when cb is called, so cb could call any method on lm.
+func (lm Locked[K, V]) Range(cb func(K, V) bool) {
+	lm.lock.RLock()
+	keys := make([]K, 0, len(lm.m))
+	for k := range lm.m {
+		keys = append(keys, k)
+	}
+	lm.lock.RUnlock()
+
+	for _, key := range keys {
+		lm.lock.RLock()
+		value, ok := lm.m[key]
+		lm.lock.RUnlock()
+		if ok && !cb(key, value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package smap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap_Implementations(t *testing.T) {
+	implementations := map[string]func() Map[int, string]{
+		"Generic":    func() Map[int, string] { return NewInteger[int, string](8, 128) },
+		"SyncMap":    func() Map[int, string] { return FromSyncMap[int, string](&sync.Map{}) },
+		"Locked":     func() Map[int, string] { return NewLocked[int, string](128) },
+		"Comparable": func() Map[int, string] { return NewIntegerComparable[int, string](8, 128) },
+	}
+	for name, factory := range implementations {
+		t.Run(name, func(t *testing.T) {
+			m := factory()
+			val, ok := m.Load(123)
+			assert.False(t, ok)
+			assert.Equal(t, "", val)
+
+			m.Store(123, "value set")
+			val, ok = m.Load(123)
+			assert.True(t, ok)
+			assert.Equal(t, "value set", val)
+
+			val, ok = m.LoadOrCreate(123, func() string { panic("no generator should be called") })
+			assert.True(t, ok)
+			assert.Equal(t, "value set", val)
+
+			val, ok = m.LoadOrCreate(456, func() string { return "new value created" })
+			assert.False(t, ok)
+			assert.Equal(t, "new value created", val)
+
+			result := make(map[int]string)
+			m.Range(func(k int, v string) bool {
+				result[k] = v
+				return true
+			})
+			assert.Equal(t, map[int]string{123: "value set", 456: "new value created"}, result)
+
+			val, ok = m.LoadAndDelete(123)
+			assert.True(t, ok)
+			assert.Equal(t, "value set", val)
+			val, ok = m.LoadAndDelete(123)
+			assert.False(t, ok)
+			assert.Equal(t, "", val)
+
+			m.Delete(456)
+			_, ok = m.Load(456)
+			assert.False(t, ok)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+// Package metrics exports statistics of smap.Generic and smap.Swiss maps in Prometheus text exposition format
+// and via expvar.
+//
+// Maps are registered in Registry by name, name is used as "map" label value. Operation and lock counters
+// are reported only for maps, created with smap.WithStats option, size metrics are reported for all maps.
+package metrics
+
+import (
+	"bufio"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+// ErrDuplicate is returned by Register, if map with the same name is already registered.
+var ErrDuplicate = errors.New("metrics: map with this name is already registered")
+
+// DefaultShardSizeBuckets are upper bounds of shard size histogram buckets, used if NewRegistry gets no buckets.
+var DefaultShardSizeBuckets = []float64{0, 16, 64, 256, 1024, 4096, 16384, 65536}
+
+// contentType is content type of Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Source is map, which statistics are exported, e.g. smap.Generic or smap.Swiss.
+type Source interface {
+	Stats() smap.Stats
+}
+
+// Registry keeps registered maps. It's safe for concurrent use.
+type Registry struct {
+	lock    sync.RWMutex
+	maps    map[string]func() smap.Stats
+	buckets []float64
+}
+
+// NewRegistry creates registry, shard sizes histogram has given buckets upper bounds, sorted in increasing order.
+// +Inf bucket is always added.
+func NewRegistry(shardSizeBuckets ...float64) *Registry {
+	if len(shardSizeBuckets) == 0 {
+		shardSizeBuckets = DefaultShardSizeBuckets
+	}
+	return &Registry{
+		maps:    make(map[string]func() smap.Stats),
+		buckets: shardSizeBuckets,
+	}
+}
+
+// Register adds map to the registry.
+func (r *Registry) Register(name string, m Source) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.maps[name]; ok {
+		return fmt.Errorf("%w: %q", ErrDuplicate, name)
+	}
+	r.maps[name] = m.Stats
+	return nil
+}
+
+// Unregister removes map from the registry.
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	delete(r.maps, name)
+	r.lock.Unlock()
+}
+
+// Handler returns http.Handler, which serves statistics of registered maps in Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_ = r.Write(w)
+	})
+}
+
+// Write writes statistics of registered maps in Prometheus text exposition format. Maps are sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	names, stats := r.collect()
+	bw := bufio.NewWriter(w)
+
+	family(bw, "smap_entries", "gauge", "Count of entries in the map.")
+	for i, name := range names {
+		fmt.Fprintf(bw, "smap_entries{map=%s} %d\n", quote(name), stats[i].Len())
+	}
+
+	family(bw, "smap_shards", "gauge", "Count of shards in the map.")
+	for i, name := range names {
+		fmt.Fprintf(bw, "smap_shards{map=%s} %d\n", quote(name), len(stats[i].ShardLens))
+	}
+
+	family(bw, "smap_shard_entries", "histogram", "Distribution of entries count per shard.")
+	for i, name := range names {
+		r.writeHistogram(bw, quote(name), stats[i].ShardLens)
+	}
+
+	// counters are written only for maps with stats, and families are omitted, if there are no such maps
+	counted := make([]int, 0, len(names))
+	for i := range stats {
+		if stats[i].Counted {
+			counted = append(counted, i)
+		}
+	}
+	if len(counted) == 0 {
+		return bw.Flush()
+	}
+
+	family(bw, "smap_operations_total", "counter", "Count of map operations.")
+	for _, i := range counted {
+		fmt.Fprintf(bw, "smap_operations_total{map=%s,op=\"load\"} %d\n", quote(names[i]), stats[i].Loads)
+		fmt.Fprintf(bw, "smap_operations_total{map=%s,op=\"store\"} %d\n", quote(names[i]), stats[i].Stores)
+		fmt.Fprintf(bw, "smap_operations_total{map=%s,op=\"delete\"} %d\n", quote(names[i]), stats[i].Deletes)
+	}
+
+	family(bw, "smap_lock_acquisitions_total", "counter", "Count of shard lock acquisitions.")
+	for _, i := range counted {
+		fmt.Fprintf(bw, "smap_lock_acquisitions_total{map=%s,mode=\"read\"} %d\n", quote(names[i]), stats[i].Reads)
+		fmt.Fprintf(bw, "smap_lock_acquisitions_total{map=%s,mode=\"write\"} %d\n", quote(names[i]), stats[i].Writes)
+	}
+
+	family(bw, "smap_lock_contended_total", "counter", "Count of shard lock attempts, which found the shard locked.")
+	for _, i := range counted {
+		fmt.Fprintf(bw, "smap_lock_contended_total{map=%s,mode=\"read\"} %d\n", quote(names[i]), stats[i].ContendedReads)
+		fmt.Fprintf(bw, "smap_lock_contended_total{map=%s,mode=\"write\"} %d\n", quote(names[i]), stats[i].ContendedWrites)
+	}
+	return bw.Flush()
+}
+
+// Publish exports statistics of registered maps as expvar variable with given name: JSON object with map names
+// as keys. Like expvar.Publish, it panics if the name is already published.
+func (r *Registry) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		names, stats := r.collect()
+		result := make(map[string]interface{}, len(names))
+		for i, name := range names {
+			vars := map[string]interface{}{
+				"entries":       stats[i].Len(),
+				"shard_entries": stats[i].ShardLens,
+			}
+			if stats[i].Counted {
+				vars["loads"] = stats[i].Loads
+				vars["stores"] = stats[i].Stores
+				vars["deletes"] = stats[i].Deletes
+				vars["reads"] = stats[i].Reads
+				vars["writes"] = stats[i].Writes
+				vars["contended_reads"] = stats[i].ContendedReads
+				vars["contended_writes"] = stats[i].ContendedWrites
+			}
+			result[name] = vars
+		}
+		return result
+	}))
+}
+
+// collect returns sorted names of registered maps and their statistics. Statistics are collected without
+// registry lock, so slow collection does not block registration.
+func (r *Registry) collect() ([]string, []smap.Stats) {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.maps))
+	for name := range r.maps {
+		names = append(names, name)
+	}
+	sources := make([]func() smap.Stats, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		sources[i] = r.maps[name]
+	}
+	r.lock.RUnlock()
+
+	stats := make([]smap.Stats, len(names))
+	for i, source := range sources {
+		stats[i] = source()
+	}
+	return names, stats
+}
+
+// writeHistogram writes cumulative buckets, sum and count of shard sizes.
+func (r *Registry) writeHistogram(w io.Writer, label string, shardLens []int) {
+	counts := make([]int, len(r.buckets))
+	sum := 0
+	for _, l := range shardLens {
+		sum += l
+		for i, bound := range r.buckets {
+			if float64(l) <= bound {
+				counts[i]++
+			}
+		}
+	}
+	for i, bound := range r.buckets {
+		fmt.Fprintf(w, "smap_shard_entries_bucket{map=%s,le=\"%s\"} %d\n", label, formatFloat(bound), counts[i])
+	}
+	fmt.Fprintf(w, "smap_shard_entries_bucket{map=%s,le=\"+Inf\"} %d\n", label, len(shardLens))
+	fmt.Fprintf(w, "smap_shard_entries_sum{map=%s} %d\n", label, sum)
+	fmt.Fprintf(w, "smap_shard_entries_count{map=%s} %d\n", label, len(shardLens))
+}
+
+func family(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelEscaper escapes label value according to the text exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry(1, 10)
+	users := smap.NewInteger[int, string](2, 0, smap.WithStats())
+	for i := 0; i < 12; i++ {
+		users.Store(i*2, "user")
+	}
+	users.Store(1, "user")
+	users.Load(1)
+	assert.NoError(t, r.Register("users", users))
+	assert.NoError(t, r.Register(`a "b"`, smap.NewInteger[int, int](1, 0)))
+	assert.ErrorIs(t, r.Register("users", users), ErrDuplicate)
+
+	server := httptest.NewServer(r.Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, contentType, resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	expected := []string{
+		"# TYPE smap_entries gauge",
+		`smap_entries{map="a \"b\""} 0`,
+		`smap_entries{map="users"} 13`,
+		`smap_shards{map="users"} 2`,
+		"# TYPE smap_shard_entries histogram",
+		`smap_shard_entries_bucket{map="users",le="1"} 1`,
+		`smap_shard_entries_bucket{map="users",le="10"} 1`,
+		`smap_shard_entries_bucket{map="users",le="+Inf"} 2`,
+		`smap_shard_entries_sum{map="users"} 13`,
+		`smap_shard_entries_count{map="users"} 2`,
+		`smap_shard_entries_bucket{map="a \"b\"",le="1"} 1`,
+		"# TYPE smap_operations_total counter",
+		`smap_operations_total{map="users",op="load"} 1`,
+		`smap_operations_total{map="users",op="store"} 13`,
+		`smap_operations_total{map="users",op="delete"} 0`,
+		`smap_lock_acquisitions_total{map="users",mode="read"} 1`,
+		`smap_lock_acquisitions_total{map="users",mode="write"} 13`,
+		`smap_lock_contended_total{map="users",mode="write"} 0`,
+	}
+	lines := strings.Split(string(body), "\n")
+	for _, line := range expected {
+		assert.Contains(t, lines, line)
+	}
+	// maps are sorted by name
+	assert.Less(t, strings.Index(string(body), `smap_entries{map="a`), strings.Index(string(body), `smap_entries{map="users"}`))
+	// counters are not reported for maps without stats
+	assert.NotContains(t, string(body), `smap_lock_acquisitions_total{map="a`)
+	assert.NotContains(t, string(body), `smap_operations_total{map="a`)
+
+	r.Unregister("users")
+	var sb strings.Builder
+	assert.NoError(t, r.Write(&sb))
+	assert.NotContains(t, sb.String(), "users")
+	assert.NotContains(t, sb.String(), "smap_lock", "counter families are omitted without maps with stats")
+}
+
+func TestRegistry_Publish(t *testing.T) {
+	r := NewRegistry()
+	m := smap.NewInteger[int, int](4, 0, smap.WithStats())
+	m.Store(1, 1)
+	assert.NoError(t, r.Register("m", m))
+	r.Publish("smap_metrics_test")
+
+	var vars map[string]struct {
+		Entries      int   `json:"entries"`
+		ShardEntries []int `json:"shard_entries"`
+		Writes       int64 `json:"writes"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(expvar.Get("smap_metrics_test").String()), &vars))
+	assert.Equal(t, 1, vars["m"].Entries)
+	assert.Len(t, vars["m"].ShardEntries, 4)
+	assert.Equal(t, int64(1), vars["m"].Writes)
+}
+
+func TestRegistry_Swiss(t *testing.T) {
+	r := NewRegistry()
+	m := smap.NewSwiss[int, int](2, 0, func(key int) uint64 {
+		return uint64(key) * 11400714819323198485
+	}, smap.WithStats())
+	m.Store(1, 1)
+	m.Store(2, 2)
+	assert.NoError(t, r.Register("swiss", m))
+
+	var sb strings.Builder
+	assert.NoError(t, r.Write(&sb))
+	assert.Contains(t, sb.String(), `smap_entries{map="swiss"} 2`)
+	assert.Contains(t, sb.String(), `smap_lock_acquisitions_total{map="swiss",mode="write"} 2`)
+}
@@ -0,0 +1,45 @@
+package smap
+
+// Option configures sharded map on creation.
+type Option func(*options)
+
+type options struct {
+	compactionRatio float64
+	integerDetector func(key uint64) int
+	lockKind        LockKind
+	stats           bool
+
+	// bloomHash is func(key K) uint64, typed by NewGeneric.
+	bloomHash              interface{}
+	bloomItems             int
+	bloomFalsePositiveRate float64
+}
+
+// WithAutoCompaction enables automatic shard compaction after mass deletion.
+// Shard map is rebuilt, when count of deletions since last rebuild exceeds ratio of current shard length
+// (and is at least minAutoCompactionDeletions, so small shards are not rebuilt too often).
+func WithAutoCompaction(ratio float64) Option {
+	return func(o *options) {
+		o.compactionRatio = ratio
+	}
+}
+
+// WithIntegerDetector sets shard detector for NewInteger and NewIntegerComparable maps, keys are converted to uint64.
+// Detector should return shard id in [0, shardsCount) range, detectors from shard package could be used, e.g.
+//
+//	smap.NewInteger[int, string](64, 128, smap.WithIntegerDetector(shard.Must(shard.Fibonacci[uint64](64))))
+//
+// Option is ignored by NewGeneric, since shard detector is its argument.
+func WithIntegerDetector(detector func(key uint64) int) Option {
+	return func(o *options) {
+		o.integerDetector = detector
+	}
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,223 @@
+package smap
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	parallelRunning int32 = iota
+	parallelStopped
+	parallelCancelled
+)
+
+// RangeParallel calls cb for each key and value present in the map, processing shards concurrently
+// from workers goroutines. If workers is not positive, GOMAXPROCS goroutines are used.
+// cb is called concurrently, and should be safe for concurrent use.
+// If cb returns false, all workers stop the iteration.
+// Consistency guarantees are the same as for Range: no locks are held when cb is called.
+// Returns ctx error if iteration was stopped because ctx is done.
+func (sm Generic[K, V]) RangeParallel(ctx context.Context, workers int, cb func(K, V) bool) error {
+	return parallelShards(ctx, sm.ShardsCount(), workers, func(id int, stopped func() bool) bool {
+		sm.locks[id].RLock()
+		keys := make([]K, 0, len(sm.shards[id]))
+		for k := range sm.shards[id] {
+			keys = append(keys, k)
+		}
+		sm.locks[id].RUnlock()
+
+		for _, key := range keys {
+			if stopped() {
+				return true
+			}
+			sm.locks[id].RLock()
+			value, ok := sm.shards[id][key]
+			sm.locks[id].RUnlock()
+			if ok && !cb(key, value) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Filter returns new map with the same shards layout, containing entries for which pred returns true.
+// Shards are processed concurrently from workers goroutines, pred is called under shard read lock,
+// so it should be safe for concurrent use and should not modify sm.
+// Returns ctx error and empty map if ctx is done before all shards are processed.
+func (sm Generic[K, V]) Filter(ctx context.Context, workers int, pred func(K, V) bool) (Generic[K, V], error) {
+	result := sm.emptyCopy()
+	err := parallelShards(ctx, sm.ShardsCount(), workers, func(id int, stopped func() bool) bool {
+		sm.locks[id].RLock()
+		defer sm.locks[id].RUnlock()
+		for key, value := range sm.shards[id] {
+			if stopped() {
+				break
+			}
+			if pred(key, value) {
+				// result shard is written only by this worker, so its lock is not needed
+				result.storing(id, key)
+				result.shards[id][key] = value
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return sm.emptyCopy(), err
+	}
+	return result, nil
+}
+
+// DeleteIf deletes entries for which pred returns true, and returns count of deleted entries.
+// Shards are processed concurrently from workers goroutines, pred is called under shard write lock,
+// so it should be safe for concurrent use and should not call methods on sm.
+// If ctx is done, returns ctx error, and count of entries deleted before it.
+func (sm Generic[K, V]) DeleteIf(ctx context.Context, workers int, pred func(K, V) bool) (int, error) {
+	var deleted int64
+	err := parallelShards(ctx, sm.ShardsCount(), workers, func(id int, stopped func() bool) bool {
+		count := 0
+		sm.locks[id].Lock()
+		for key, value := range sm.shards[id] {
+			if stopped() {
+				break
+			}
+			if pred(key, value) {
+				delete(sm.shards[id], key)
+				sm.removed(id, key)
+				count++
+			}
+		}
+		sm.deleted(id, count)
+		sm.locks[id].Unlock()
+		atomic.AddInt64(&deleted, int64(count))
+		return true
+	})
+	return int(deleted), err
+}
+
+// RetainIf keeps only entries for which pred returns true, and returns count of deleted entries.
+// See DeleteIf for concurrency details.
+func (sm Generic[K, V]) RetainIf(ctx context.Context, workers int, pred func(K, V) bool) (int, error) {
+	return sm.DeleteIf(ctx, workers, func(key K, value V) bool {
+		return !pred(key, value)
+	})
+}
+
+// MapValues returns new map with the same shards layout, containing results of fn for each entry of sm.
+// Shards are processed concurrently from workers goroutines, fn is called under shard read lock,
+// so it should be safe for concurrent use and should not modify sm.
+// Returns ctx error and empty map if ctx is done before all shards are processed.
+func MapValues[K comparable, V, W any](ctx context.Context, sm Generic[K, V], workers int, fn func(K, V) W) (Generic[K, W], error) {
+	result := newGeneric[K, W](sm.ShardsCount(), 0, sm.shardDetector, sm.options)
+	err := parallelShards(ctx, sm.ShardsCount(), workers, func(id int, stopped func() bool) bool {
+		sm.locks[id].RLock()
+		defer sm.locks[id].RUnlock()
+		result.shards[id] = make(map[K]W, len(sm.shards[id]))
+		for key, value := range sm.shards[id] {
+			if stopped() {
+				break
+			}
+			result.storing(id, key)
+			result.shards[id][key] = fn(key, value)
+		}
+		return true
+	})
+	if err != nil {
+		return newGeneric[K, W](sm.ShardsCount(), 0, sm.shardDetector, sm.options), err
+	}
+	return result, nil
+}
+
+// Reduce folds entries of each shard with fn, starting from initial value, and then merges shard results with merge.
+// Since initial value is used for each shard, it should be identity for merge (e.g. zero for sum).
+// Shards are processed concurrently from workers goroutines, fn is called under shard read lock,
+// so it should not modify sm. merge is called sequentially in shards order.
+// Returns ctx error if ctx is done before all shards are processed.
+func Reduce[K comparable, V, A any](ctx context.Context, sm Generic[K, V], workers int, initial A, fn func(A, K, V) A, merge func(A, A) A) (A, error) {
+	results := make([]A, sm.ShardsCount())
+	err := parallelShards(ctx, sm.ShardsCount(), workers, func(id int, stopped func() bool) bool {
+		acc := initial
+		sm.locks[id].RLock()
+		defer sm.locks[id].RUnlock()
+		for key, value := range sm.shards[id] {
+			if stopped() {
+				break
+			}
+			acc = fn(acc, key, value)
+		}
+		results[id] = acc
+		return true
+	})
+	if err != nil {
+		return initial, err
+	}
+
+	result := initial
+	for i := range results {
+		result = merge(result, results[i])
+	}
+	return result, nil
+}
+
+// emptyCopy returns empty map with the same shards count, shard detector and options.
+func (sm Generic[K, V]) emptyCopy() Generic[K, V] {
+	return newGeneric[K, V](sm.ShardsCount(), 0, sm.shardDetector, sm.options)
+}
+
+// parallelShards calls fn for each shard id from workers goroutines, until all shards are processed,
+// fn returns false, or ctx is done. fn should check stopped periodically, and return when it's true.
+// Returns ctx error if processing was stopped because ctx is done.
+func parallelShards(ctx context.Context, shardsCount, workers int, fn func(id int, stopped func() bool) bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > shardsCount {
+		workers = shardsCount
+	}
+
+	var (
+		next  int64
+		state int32
+	)
+	done := ctx.Done()
+	stopped := func() bool {
+		if atomic.LoadInt32(&state) != parallelRunning {
+			return true
+		}
+		select {
+		case <-done:
+			atomic.CompareAndSwapInt32(&state, parallelRunning, parallelCancelled)
+			return true
+		default:
+			return false
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for !stopped() {
+				id := int(atomic.AddInt64(&next, 1) - 1)
+				if id >= shardsCount {
+					return
+				}
+				if !fn(id, stopped) {
+					atomic.CompareAndSwapInt32(&state, parallelRunning, parallelStopped)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&state) == parallelCancelled {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package smap
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneric_RangeParallel(t *testing.T) {
+	m := NewInteger[int, int](16, 128)
+	expected := make(map[int]int, 1024)
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i*i)
+		expected[i] = i * i
+	}
+
+	var mu sync.Mutex
+	result := make(map[int]int, 1024)
+	err := m.RangeParallel(context.Background(), 4, func(k int, v int) bool {
+		mu.Lock()
+		result[k] = v
+		mu.Unlock()
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func TestGeneric_RangeParallelStop(t *testing.T) {
+	m := NewInteger[int, int](16, 128)
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i)
+	}
+
+	var calls int64
+	err := m.RangeParallel(context.Background(), 4, func(k int, v int) bool {
+		atomic.AddInt64(&calls, 1)
+		return false
+	})
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, calls, int64(4)) // each worker stops after at most one call
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	err = m.RangeParallel(ctx, 4, func(k int, v int) bool {
+		if atomic.AddInt64(&calls, 1) == 10 {
+			cancel()
+		}
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, calls, int64(1024))
+
+	err = m.RangeParallel(ctx, 4, func(k int, v int) bool {
+		panic("cb should not be called with done context")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGeneric_Filter(t *testing.T) {
+	m := NewInteger[int, int](16, 128)
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i)
+	}
+
+	even, err := m.Filter(context.Background(), 0, func(k int, v int) bool {
+		return k%2 == 0
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, m.ShardsCount(), even.ShardsCount())
+	for i := 0; i < 1024; i++ {
+		_, ok := even.Load(i)
+		assert.Equal(t, i%2 == 0, ok)
+		_, ok = m.Load(i) // source map is not changed
+		assert.True(t, ok)
+	}
+}
+
+func TestGeneric_FilterKeepsOptions(t *testing.T) {
+	m := NewInteger[int, int](4, 0, WithLock(LockMutex), WithStats(), WithAutoCompaction(0.5), WithBloomFilter(intHash, 1024, 0.01))
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i)
+	}
+
+	even, err := m.Filter(context.Background(), 0, func(k int, v int) bool {
+		return k%2 == 0
+	})
+	assert.NoError(t, err)
+	assert.IsType(t, &mutexLock{}, even.locks[0].ext.custom)
+	assert.NotNil(t, even.locks[0].ext.stats)
+	assert.NotNil(t, even.deletions)
+	assert.NotNil(t, even.blooms)
+	for i := 0; i < 1024; i++ {
+		_, ok := even.Load(i) // Bloom filter of the result is filled
+		assert.Equal(t, i%2 == 0, ok)
+	}
+
+	squares, err := MapValues(context.Background(), m, 0, func(k int, v int) int64 {
+		return int64(v) * int64(v)
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, squares.blooms)
+	v, ok := squares.Load(30)
+	assert.True(t, ok)
+	assert.Equal(t, int64(900), v)
+}
+
+func TestGeneric_DeleteIf(t *testing.T) {
+	m := NewInteger[int, int](16, 128)
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i)
+	}
+
+	deleted, err := m.DeleteIf(context.Background(), 4, func(k int, v int) bool {
+		return k%4 == 0
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 256, deleted)
+
+	deleted, err = m.RetainIf(context.Background(), 4, func(k int, v int) bool {
+		return k%2 == 0
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 512, deleted)
+
+	for i := 0; i < 1024; i++ {
+		_, ok := m.Load(i)
+		assert.Equal(t, i%4 == 2, ok)
+	}
+}
+
+func TestMapValuesReduce(t *testing.T) {
+	m := NewInteger[int, int](16, 128)
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i)
+	}
+
+	squares, err := MapValues(context.Background(), m, 4, func(k int, v int) int64 {
+		return int64(v) * int64(v)
+	})
+	assert.NoError(t, err)
+	val, ok := squares.Load(100)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10000), val)
+
+	sum, err := Reduce(context.Background(), squares, 4, int64(0), func(acc int64, k int, v int64) int64 {
+		return acc + v
+	}, func(a, b int64) int64 {
+		return a + b
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1023*1024*2047/6), sum)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Reduce(ctx, squares, 4, int64(0), func(acc int64, k int, v int64) int64 {
+		return acc + v
+	}, func(a, b int64) int64 {
+		return a + b
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
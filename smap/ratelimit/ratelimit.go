@@ -0,0 +1,297 @@
+// Package ratelimit provides per-key token bucket rate limiter, backed by smap.Generic sharded map.
+//
+// Each key has its own bucket, which holds up to Burst tokens and is refilled with Rate tokens per second.
+// Buckets are created on the first request and are kept in the map shard of the key, so requests for keys
+// in different shards do not contend. Buckets, which were not used for Config.IdleTimeout and are full again,
+// are evicted by Evict calls or periodically, so the map does not grow with every client seen.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+var (
+	// ErrExceedsBurst is returned by WaitN, if n is greater than burst of the key limit, so it's never allowed.
+	ErrExceedsBurst = errors.New("ratelimit: requested tokens count exceeds burst")
+	// ErrWouldExceedDeadline is returned by WaitN, if tokens would not be available before context deadline.
+	ErrWouldExceedDeadline = errors.New("ratelimit: wait would exceed context deadline")
+)
+
+// Limit is token bucket parameters.
+type Limit struct {
+	// Rate is count of tokens, added to bucket per second. Bucket is not refilled, if Rate is not positive.
+	Rate float64
+	// Burst is bucket capacity, and the max count of tokens, which could be taken at once.
+	Burst int
+}
+
+// Clock provides current time and timers to Limiter, so it could be replaced in tests.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns channel, which receives the time after d, and func, which stops the timer.
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+// Config configures Limiter. Zero fields, except for Limit, are replaced with defaults.
+type Config struct {
+	// Limit is default limit of each key, could be overridden with SetLimit.
+	Limit Limit
+	// IdleTimeout is period without requests, after which full bucket could be evicted, 1 minute by default.
+	// Full bucket is equivalent to missing one, so eviction does not change limiter decisions.
+	IdleTimeout time.Duration
+	// EvictInterval is period of automatic eviction. If zero, buckets are evicted only by Evict calls.
+	EvictInterval time.Duration
+	// Clock is system clock by default.
+	Clock Clock
+}
+
+// Limiter is per-key token bucket rate limiter. It's safe for concurrent use.
+type Limiter[K comparable] struct {
+	buckets smap.Generic[K, bucket]
+	limits  smap.Generic[K, Limit]
+	state   *limiterState
+}
+
+type limiterState struct {
+	config   Config
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// bucket is token bucket state. Tokens could be negative, when they are reserved by waiting WaitN calls.
+type bucket struct {
+	limit  Limit
+	tokens float64
+	// last is time of the last refill, and the last request.
+	last time.Time
+}
+
+// New creates limiter with buckets, distributed to shardsCount shards by shardDetector.
+// If config.EvictInterval is set, Close should be called to stop eviction goroutine.
+func New[K comparable](shardsCount int, shardDetector func(key K) int, config Config) Limiter[K] {
+	config = config.withDefaults()
+	l := Limiter[K]{
+		buckets: smap.NewGeneric[K, bucket](shardsCount, 0, shardDetector),
+		limits:  smap.NewGeneric[K, Limit](shardsCount, 0, shardDetector),
+		state: &limiterState{
+			config: config,
+			stop:   make(chan struct{}),
+		},
+	}
+	if config.EvictInterval > 0 {
+		go l.evictLoop()
+	}
+	return l
+}
+
+func (c Config) withDefaults() Config {
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = time.Minute
+	}
+	if c.Clock == nil {
+		c.Clock = systemClock{}
+	}
+	return c
+}
+
+// Allow reports whether one token could be taken for key now, and takes it.
+func (l Limiter[K]) Allow(key K) bool {
+	return l.AllowN(key, 1)
+}
+
+// AllowN reports whether n tokens could be taken for key now, and takes them. Tokens are not taken partially.
+func (l Limiter[K]) AllowN(key K, n int) bool {
+	now := l.state.config.Clock.Now()
+	allowed := false
+	l.buckets.WithValue(key, func(b *bucket, exists bool) bool {
+		l.refill(key, b, exists, now)
+		if b.tokens >= float64(n) {
+			b.tokens -= float64(n)
+			allowed = true
+		}
+		return true
+	})
+	return allowed
+}
+
+// Wait blocks until one token could be taken for key, or ctx is done. See WaitN for details.
+func (l Limiter[K]) Wait(ctx context.Context, key K) error {
+	return l.WaitN(ctx, key, 1)
+}
+
+// WaitN blocks until n tokens could be taken for key, or ctx is done. Tokens are reserved before waiting,
+// so waiters are served in order of calls, and reserved tokens are returned if ctx is done.
+// Returns ErrExceedsBurst if n is greater than key burst, or missing tokens are never refilled since rate is not
+// positive, and ErrWouldExceedDeadline without waiting,
+// if tokens would be available only after ctx deadline.
+func (l Limiter[K]) WaitN(ctx context.Context, key K, n int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := l.state.config.Clock.Now()
+	deadline, hasDeadline := ctx.Deadline()
+	var (
+		delay time.Duration
+		err   error
+	)
+	l.buckets.WithValue(key, func(b *bucket, exists bool) bool {
+		l.refill(key, b, exists, now)
+		if n > b.limit.Burst {
+			err = ErrExceedsBurst
+			return true
+		}
+		missing := float64(n) - b.tokens
+		if missing > 0 {
+			if b.limit.Rate <= 0 {
+				err = ErrExceedsBurst
+				return true
+			}
+			delay = time.Duration(math.Ceil(missing / b.limit.Rate * float64(time.Second)))
+			if hasDeadline && now.Add(delay).After(deadline) {
+				err = ErrWouldExceedDeadline
+				return true
+			}
+		}
+		b.tokens -= float64(n)
+		return true
+	})
+	if err != nil || delay == 0 {
+		return err
+	}
+
+	timer, stop := l.state.config.Clock.NewTimer(delay)
+	select {
+	case <-timer:
+		return nil
+	case <-ctx.Done():
+		stop()
+		l.buckets.WithValue(key, func(b *bucket, exists bool) bool {
+			if exists {
+				b.tokens = math.Min(b.tokens+float64(n), float64(b.limit.Burst))
+			}
+			return exists
+		})
+		return ctx.Err()
+	}
+}
+
+// SetLimit overrides limit of the key. Override is kept, until it's removed with ResetLimit.
+// Tokens of existing bucket are refilled with the previous rate up to now, and are capped by the new burst.
+func (l Limiter[K]) SetLimit(key K, limit Limit) {
+	l.updateLimit(key, limit, func() {
+		l.limits.Store(key, limit)
+	})
+}
+
+// ResetLimit removes limit override of the key, so the default limit is used.
+func (l Limiter[K]) ResetLimit(key K) {
+	l.updateLimit(key, l.state.config.Limit, func() {
+		l.limits.Delete(key)
+	})
+}
+
+// Tokens returns count of tokens, available for key now. It's negative, if tokens are reserved by WaitN calls.
+func (l Limiter[K]) Tokens(key K) float64 {
+	now := l.state.config.Clock.Now()
+	var b bucket
+	if !l.buckets.ReadValue(key, func(v *bucket) { b = *v }) {
+		return float64(l.limit(key).Burst)
+	}
+	return b.tokensAt(now)
+}
+
+// Len returns count of buckets in the limiter.
+func (l Limiter[K]) Len() int {
+	return l.buckets.Stats().Len()
+}
+
+// Evict deletes buckets, which were not used for IdleTimeout and are full, and returns count of deleted buckets.
+func (l Limiter[K]) Evict() int {
+	now := l.state.config.Clock.Now()
+	idleTimeout := l.state.config.IdleTimeout
+	count, _ := l.buckets.DeleteIf(context.Background(), 1, func(_ K, b bucket) bool {
+		return now.Sub(b.last) >= idleTimeout && b.tokensAt(now) >= float64(b.limit.Burst)
+	})
+	return count
+}
+
+// Close stops eviction goroutine, if it was started.
+func (l Limiter[K]) Close() {
+	l.state.stopOnce.Do(func() {
+		close(l.state.stop)
+	})
+}
+
+func (l Limiter[K]) evictLoop() {
+	ticker := time.NewTicker(l.state.config.EvictInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.Evict()
+		case <-l.state.stop:
+			return
+		}
+	}
+}
+
+// refill initializes missing bucket with full one, or adds tokens for time passed since the last refill.
+func (l Limiter[K]) refill(key K, b *bucket, exists bool, now time.Time) {
+	if !exists {
+		limit := l.limit(key)
+		*b = bucket{limit: limit, tokens: float64(limit.Burst), last: now}
+		return
+	}
+	b.tokens = b.tokensAt(now)
+	if now.After(b.last) {
+		b.last = now
+	}
+}
+
+// updateLimit calls override, which changes limit override of the key, and sets limit of existing bucket.
+// Both are done under bucket shard lock, so concurrent updates can't leave bucket with limit, other than override.
+func (l Limiter[K]) updateLimit(key K, limit Limit, override func()) {
+	now := l.state.config.Clock.Now()
+	l.buckets.WithValue(key, func(b *bucket, exists bool) bool {
+		override()
+		if exists {
+			l.refill(key, b, exists, now)
+			b.limit = limit
+			b.tokens = math.Min(b.tokens, float64(limit.Burst))
+		}
+		return exists
+	})
+}
+
+func (l Limiter[K]) limit(key K) Limit {
+	if limit, ok := l.limits.Load(key); ok {
+		return limit
+	}
+	return l.state.config.Limit
+}
+
+// tokensAt returns count of tokens at given time, without modifying the bucket.
+func (b bucket) tokensAt(now time.Time) float64 {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 || b.limit.Rate <= 0 {
+		return b.tokens
+	}
+	return math.Min(b.tokens+elapsed.Seconds()*b.limit.Rate, float64(b.limit.Burst))
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
@@ -0,0 +1,261 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+// fakeClock is manually advanced clock. Timers fire, when clock is advanced past their deadline.
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	at      time.Time
+	c       chan time.Time
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	// context deadlines are compared with real time, so fake time starts from it
+	return &fakeClock{now: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	t := &fakeTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t.c, func() bool {
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		active := !t.stopped
+		t.stopped = true
+		return active
+	}
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		switch {
+		case t.stopped:
+		case !t.at.After(c.now):
+			t.stopped = true
+			t.c <- c.now
+		default:
+			timers = append(timers, t)
+		}
+	}
+	c.timers = timers
+}
+
+func (c *fakeClock) Timers() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.timers)
+}
+
+func newLimiter(clock *fakeClock, limit Limit) Limiter[int] {
+	return New[int](4, shard.Must(shard.Modulo[int](4)), Config{
+		Limit:       limit,
+		IdleTimeout: time.Minute,
+		Clock:       clock,
+	})
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(clock, Limit{Rate: 2, Burst: 3})
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow(1))
+	}
+	assert.False(t, l.Allow(1))
+	assert.True(t, l.Allow(2), "keys have separate buckets")
+
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, l.Allow(1))
+	assert.False(t, l.Allow(1))
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, 3.0, l.Tokens(1), "bucket is refilled up to burst")
+	assert.False(t, l.AllowN(1, 4))
+	assert.True(t, l.AllowN(1, 3))
+	assert.Equal(t, 0.0, l.Tokens(1))
+}
+
+func TestLimiter_SetLimit(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(clock, Limit{Rate: 1, Burst: 1})
+	l.SetLimit(1, Limit{Rate: 10, Burst: 5})
+	assert.True(t, l.AllowN(1, 5))
+	assert.False(t, l.AllowN(2, 2))
+
+	clock.Advance(100 * time.Millisecond)
+	assert.Equal(t, 1.0, l.Tokens(1))
+
+	l.ResetLimit(1)
+	clock.Advance(time.Second)
+	assert.Equal(t, 1.0, l.Tokens(1), "default burst caps the bucket")
+
+	l.SetLimit(3, Limit{Burst: 2})
+	assert.True(t, l.AllowN(3, 2))
+	clock.Advance(time.Hour)
+	assert.False(t, l.Allow(3), "bucket without rate is not refilled")
+	assert.ErrorIs(t, l.Wait(context.Background(), 3), ErrExceedsBurst)
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(clock, Limit{Rate: 1, Burst: 2})
+	ctx := context.Background()
+
+	assert.NoError(t, l.WaitN(ctx, 1, 2))
+	assert.ErrorIs(t, l.WaitN(ctx, 1, 3), ErrExceedsBurst)
+
+	done := make(chan error)
+	go func() {
+		done <- l.Wait(ctx, 1)
+	}()
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, -1.0, l.Tokens(1), "token is reserved by waiter")
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("wait returned before token is available")
+	default:
+	}
+	clock.Advance(500 * time.Millisecond)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 0.0, l.Tokens(1))
+}
+
+func TestLimiter_WaitContext(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(clock, Limit{Rate: 0.01, Burst: 1})
+	assert.True(t, l.Allow(1))
+
+	deadlineCtx, cancelDeadline := context.WithDeadline(context.Background(), clock.Now().Add(time.Minute))
+	defer cancelDeadline()
+	assert.ErrorIs(t, l.Wait(deadlineCtx, 1), ErrWouldExceedDeadline)
+	assert.Equal(t, 0.0, l.Tokens(1), "token is not reserved")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- l.Wait(ctx, 1)
+	}()
+	for clock.Timers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, 0.0, l.Tokens(1), "reserved token is returned")
+}
+
+func TestLimiter_Evict(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(clock, Limit{Rate: 1, Burst: 100})
+	for i := 0; i < 10; i++ {
+		assert.True(t, l.Allow(i))
+	}
+	clock.Advance(30 * time.Second)
+	assert.True(t, l.Allow(0))
+	assert.Equal(t, 10, l.Len())
+
+	clock.Advance(20 * time.Second)
+	assert.Zero(t, l.Evict(), "buckets are not idle for IdleTimeout yet")
+
+	clock.Advance(20 * time.Second)
+	assert.Equal(t, 9, l.Evict(), "key 0 bucket is used recently")
+
+	l.SetLimit(0, Limit{Rate: 0.0001, Burst: 100})
+	assert.True(t, l.Allow(0))
+	clock.Advance(time.Hour)
+	assert.Zero(t, l.Evict(), "bucket, which is not full, is kept")
+	assert.Equal(t, 1, l.Len())
+}
+
+func TestLimiter_EvictInterval(t *testing.T) {
+	l := New[int](4, shard.Must(shard.Modulo[int](4)), Config{
+		Limit:         Limit{Rate: 1000, Burst: 1},
+		IdleTimeout:   time.Millisecond,
+		EvictInterval: time.Millisecond,
+	})
+	defer l.Close()
+	assert.True(t, l.Allow(1))
+	assert.Eventually(t, func() bool {
+		return l.Len() == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestLimiter_Concurrent(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(clock, Limit{Rate: 1, Burst: 100})
+	var (
+		wg      sync.WaitGroup
+		allowed int64
+		lock    sync.Mutex
+	)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if l.Allow(i % 2) {
+					lock.Lock()
+					allowed++
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(200), allowed)
+}
+
+func TestLimiter_ConcurrentSetLimit(t *testing.T) {
+	clock := newFakeClock()
+	l := newLimiter(clock, Limit{Rate: 1, Burst: 1})
+	l.Allow(1) // bucket exists
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if g%2 == 0 {
+					l.SetLimit(1, Limit{Rate: float64(g), Burst: g + i})
+				} else {
+					l.ResetLimit(1)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var b bucket
+	assert.True(t, l.buckets.ReadValue(1, func(v *bucket) { b = *v }))
+	assert.Equal(t, l.limit(1), b.limit, "bucket enforces stored limit")
+}
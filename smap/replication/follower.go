@@ -0,0 +1,150 @@
+package replication
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+// Follower applies mutation stream of the Leader to its map.
+// Follower map could have different shards layout, entries are applied with Store and Delete.
+// Follower remembers epoch of the leader and sequence of the last applied mutation, so it could resume after reconnect.
+type Follower[K comparable, V any] struct {
+	m     smap.Generic[K, V]
+	state *followerState
+}
+
+type followerState struct {
+	// lock serializes streams applied concurrently.
+	lock      sync.Mutex
+	epoch     uint64
+	lastSeq   uint64
+	fullSyncs uint64
+
+	// streamEpoch is epoch from header of the stream being applied.
+	streamEpoch uint64
+
+	// snapshotSeqs keeps sequence of the last mutation included to each leader shard snapshot,
+	// until all mutations up to snapshotsUntil are applied.
+	snapshotSeqs   map[int]uint64
+	snapshotsUntil uint64
+}
+
+// NewFollower creates follower, applying mutations to m. m should not be modified by other means.
+func NewFollower[K comparable, V any](m smap.Generic[K, V]) Follower[K, V] {
+	return Follower[K, V]{
+		m:     m,
+		state: &followerState{},
+	}
+}
+
+// Map returns follower map. It should be used only for reads.
+// During full sync map is cleared, and then filled shard by shard.
+func (f Follower[K, V]) Map() smap.Generic[K, V] {
+	return f.m
+}
+
+// LastSeq returns sequence of the last applied mutation. Zero means follower needs full sync.
+func (f Follower[K, V]) LastSeq() uint64 {
+	return atomic.LoadUint64(&f.state.lastSeq)
+}
+
+// Epoch returns epoch of the leader, which mutations are applied. Zero means follower needs full sync.
+func (f Follower[K, V]) Epoch() uint64 {
+	return atomic.LoadUint64(&f.state.epoch)
+}
+
+// FullSyncs returns count of full syncs received from leaders.
+func (f Follower[K, V]) FullSyncs() int {
+	return int(atomic.LoadUint64(&f.state.fullSyncs))
+}
+
+// Sync sends epoch and sequence of the last applied mutation to rw, and then applies stream, sent by Leader.Serve.
+// Sync returns when rw is closed or read fails. Returns nil if stream ended with io.EOF.
+func (f Follower[K, V]) Sync(rw io.ReadWriter) error {
+	if err := gob.NewEncoder(rw).Encode(&hello{Epoch: f.Epoch(), LastSeq: f.LastSeq()}); err != nil {
+		return err
+	}
+	return f.Apply(rw)
+}
+
+// Apply applies stream, written by Leader.WriteTo, until r is exhausted or read fails.
+// Stream should be started from Epoch and LastSeq of the follower. Returns nil if stream ended with io.EOF.
+func (f Follower[K, V]) Apply(r io.Reader) error {
+	f.state.lock.Lock()
+	defer f.state.lock.Unlock()
+	f.state.streamEpoch = 0
+
+	dec := gob.NewDecoder(r)
+	for {
+		var msg message[K, V]
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := f.apply(&msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (f Follower[K, V]) apply(msg *message[K, V]) error {
+	s := f.state
+	if msg.Kind != kindHeader && s.streamEpoch == 0 {
+		return errors.New("replication: stream does not start with header")
+	}
+	switch msg.Kind {
+	case kindHeader:
+		s.streamEpoch = msg.Epoch
+	case kindReset:
+		// map is inconsistent until snapshot is done, so it should not be resumed from the previous sequence
+		atomic.StoreUint64(&s.lastSeq, 0)
+		atomic.StoreUint64(&s.epoch, msg.Epoch)
+		atomic.AddUint64(&s.fullSyncs, 1)
+		s.snapshotSeqs = make(map[int]uint64)
+		s.snapshotsUntil = 0
+		_, _ = f.m.DeleteIf(context.Background(), 0, func(K, V) bool {
+			return true
+		})
+	case kindSnapshot:
+		for _, e := range msg.Entries {
+			f.m.Store(e.Key, e.Value)
+		}
+		s.snapshotSeqs[msg.Shard] = msg.Seq
+		if msg.Seq > s.snapshotsUntil {
+			s.snapshotsUntil = msg.Seq
+		}
+	case kindSnapshotDone:
+		atomic.StoreUint64(&s.lastSeq, msg.Seq)
+	case kindMutation:
+		if epoch := atomic.LoadUint64(&s.epoch); epoch != s.streamEpoch {
+			return fmt.Errorf("replication: mutation of leader epoch %d, while state is of epoch %d", s.streamEpoch, epoch)
+		}
+		lastSeq := atomic.LoadUint64(&s.lastSeq)
+		if lastSeq == 0 || msg.Seq != lastSeq+1 {
+			return fmt.Errorf("replication: unexpected mutation %d after %d", msg.Seq, lastSeq)
+		}
+		if msg.Seq > s.snapshotSeqs[msg.Shard] {
+			if msg.Delete {
+				f.m.Delete(msg.Key)
+			} else {
+				f.m.Store(msg.Key, msg.Value)
+			}
+		}
+		if msg.Seq >= s.snapshotsUntil {
+			s.snapshotSeqs = nil
+		}
+		atomic.StoreUint64(&s.lastSeq, msg.Seq)
+	default:
+		return fmt.Errorf("replication: unknown message kind %d", msg.Kind)
+	}
+	return nil
+}
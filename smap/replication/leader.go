@@ -0,0 +1,182 @@
+package replication
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+// Leader wraps smap.Generic and logs each mutation, so it could be streamed to followers.
+// All mutations of the map should go through Leader, mutations made directly on wrapped map are not replicated.
+type Leader[K comparable, V any] struct {
+	m     smap.Generic[K, V]
+	log   *mutationLog[K, V]
+	epoch uint64
+}
+
+var _ smap.Map[int, int] = Leader[int, int]{}
+
+// NewLeader creates leader for the map, keeping up to logSize latest mutations for resuming followers.
+// If logSize is not positive, DefaultLogSize is used. Log should be large enough to keep mutations
+// made while full sync snapshot is transferred, otherwise follower gets ErrLagging right after sync.
+// Leader gets random epoch, so followers of the previous leader get full sync instead of resuming.
+func NewLeader[K comparable, V any](m smap.Generic[K, V], logSize int) Leader[K, V] {
+	if logSize <= 0 {
+		logSize = DefaultLogSize
+	}
+	return Leader[K, V]{
+		m:     m,
+		log:   newMutationLog[K, V](logSize),
+		epoch: newEpoch(),
+	}
+}
+
+// Epoch returns random epoch of the leader, it's sent in header of each stream.
+func (l Leader[K, V]) Epoch() uint64 {
+	return l.epoch
+}
+
+// Map returns wrapped map. It should be used only for reads.
+func (l Leader[K, V]) Map() smap.Generic[K, V] {
+	return l.m
+}
+
+// LastSeq returns sequence of the last mutation.
+func (l Leader[K, V]) LastSeq() uint64 {
+	return l.log.lastSeq()
+}
+
+// Load returns the value stored in the map for a key.
+func (l Leader[K, V]) Load(key K) (V, bool) {
+	return l.m.Load(key)
+}
+
+// Store sets the value for a key.
+func (l Leader[K, V]) Store(key K, value V) {
+	l.m.WithValue(key, func(v *V, exists bool) bool {
+		*v = value
+		l.log.append(mutation[K, V]{shard: l.m.ShardID(key), key: key, value: value})
+		return true
+	})
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+func (l Leader[K, V]) LoadAndDelete(key K) (value V, loaded bool) {
+	l.m.WithValue(key, func(v *V, exists bool) bool {
+		if exists {
+			value, loaded = *v, true
+			l.log.append(mutation[K, V]{shard: l.m.ShardID(key), delete: true, key: key})
+		}
+		return false
+	})
+	return value, loaded
+}
+
+// LoadOrCreate returns the existing value for the key if present.
+// Otherwise, it calls generator func, stores and returns the generator's result.
+func (l Leader[K, V]) LoadOrCreate(key K, generator func() V) (value V, loaded bool) {
+	if value, loaded = l.m.Load(key); loaded {
+		return value, loaded
+	}
+	l.m.WithValue(key, func(v *V, exists bool) bool {
+		if !exists {
+			*v = generator()
+			l.log.append(mutation[K, V]{shard: l.m.ShardID(key), key: key, value: *v})
+		}
+		value, loaded = *v, exists
+		return true
+	})
+	return value, loaded
+}
+
+// Delete deletes the value for a key.
+func (l Leader[K, V]) Delete(key K) {
+	l.LoadAndDelete(key)
+}
+
+// Range calls cb sequentially for each key and value present in the map, see smap.Generic.Range.
+func (l Leader[K, V]) Range(cb func(K, V) bool) {
+	l.m.Range(cb)
+}
+
+// Serve reads epoch and sequence of the last mutation applied by follower from rw, and then streams mutations to rw
+// until ctx is done or write fails. See WriteTo.
+func (l Leader[K, V]) Serve(ctx context.Context, rw io.ReadWriter) error {
+	var h hello
+	if err := gob.NewDecoder(rw).Decode(&h); err != nil {
+		return err
+	}
+	return l.WriteTo(ctx, rw, h.Epoch, h.LastSeq)
+}
+
+// WriteTo writes header with leader epoch, and streams mutations following lastSeq to w until ctx is done
+// or write fails. If epoch differs from the leader one, or the log does not contain all mutations after lastSeq
+// (or lastSeq is zero), snapshots of all shards are sent first.
+// Returns ErrLagging if follower reads the stream slower than mutations are dropped from the log.
+func (l Leader[K, V]) WriteTo(ctx context.Context, w io.Writer, epoch, lastSeq uint64) error {
+	defer l.log.wakeOnDone(ctx)()
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(&message[K, V]{Kind: kindHeader, Epoch: l.epoch}); err != nil {
+		return err
+	}
+
+	seq := lastSeq
+	if epoch != l.epoch || !l.log.canResume(lastSeq) {
+		var err error
+		if seq, err = l.writeSnapshot(ctx, enc); err != nil {
+			return err
+		}
+	}
+
+	for {
+		batch, err := l.log.wait(ctx, seq, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, m := range batch {
+			msg := message[K, V]{Kind: kindMutation, Seq: m.seq, Shard: m.shard, Delete: m.delete, Key: m.key, Value: m.value}
+			if err = enc.Encode(&msg); err != nil {
+				return err
+			}
+		}
+		seq = batch[len(batch)-1].seq
+	}
+}
+
+// writeSnapshot sends entries of each shard, with sequence of the last mutation included to shard snapshot.
+// Shards are locked one by one, so snapshot is not consistent: follower skips mutations of the shard,
+// which are already included to its snapshot. Returns sequence to continue streaming from.
+func (l Leader[K, V]) writeSnapshot(ctx context.Context, enc *gob.Encoder) (uint64, error) {
+	if err := enc.Encode(&message[K, V]{Kind: kindReset, Epoch: l.epoch}); err != nil {
+		return 0, err
+	}
+
+	// each shard snapshot includes all mutations up to this one
+	from := l.log.lastSeq()
+	var entries []entry[K, V]
+	for id := 0; id < l.m.ShardsCount(); id++ {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		entries = entries[:0]
+		l.m.RLockShard(id)
+		// mutations are logged under shard write lock, so seq is exactly the last mutation of this shard snapshot
+		seq := l.log.lastSeq()
+		l.m.UnblockedShardRange(id, func(key K, value V) bool {
+			entries = append(entries, entry[K, V]{Key: key, Value: value})
+			return true
+		})
+		l.m.RUnlockShard(id)
+
+		if err := enc.Encode(&message[K, V]{Kind: kindSnapshot, Seq: seq, Shard: id, Entries: entries}); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := enc.Encode(&message[K, V]{Kind: kindSnapshotDone, Seq: from}); err != nil {
+		return 0, err
+	}
+	return from, nil
+}
@@ -0,0 +1,111 @@
+package replication
+
+import (
+	"context"
+	"sync"
+)
+
+// mutation is single change of the leader map. Leader shard is kept, so follower could skip mutations,
+// that are already included to shard snapshot.
+type mutation[K comparable, V any] struct {
+	seq    uint64
+	shard  int
+	delete bool
+	key    K
+	value  V
+}
+
+// mutationLog keeps the latest mutations in ring buffer, so followers could resume from sequence.
+type mutationLog[K comparable, V any] struct {
+	lock    sync.Mutex
+	updated *sync.Cond
+	entries []mutation[K, V]
+	start   int    // index of the oldest entry
+	count   int    // count of entries in buffer
+	last    uint64 // sequence of the last mutation
+}
+
+func newMutationLog[K comparable, V any](size int) *mutationLog[K, V] {
+	l := &mutationLog[K, V]{
+		entries: make([]mutation[K, V], size),
+	}
+	l.updated = sync.NewCond(&l.lock)
+	return l
+}
+
+// append assigns sequence to mutation, and adds it to the log, dropping the oldest one if log is full.
+func (l *mutationLog[K, V]) append(m mutation[K, V]) {
+	l.lock.Lock()
+	l.last++
+	m.seq = l.last
+	if l.count == len(l.entries) {
+		l.entries[l.start] = m
+		l.start = (l.start + 1) % len(l.entries)
+	} else {
+		l.entries[(l.start+l.count)%len(l.entries)] = m
+		l.count++
+	}
+	l.lock.Unlock()
+	l.updated.Broadcast()
+}
+
+func (l *mutationLog[K, V]) lastSeq() uint64 {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.last
+}
+
+// canResume reports whether all mutations after given sequence are kept in the log.
+func (l *mutationLog[K, V]) canResume(seq uint64) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return seq != 0 && seq <= l.last && seq+uint64(l.count) >= l.last
+}
+
+// wait returns up to limit mutations after given sequence, waiting for them if there are no new ones yet.
+// Returns ErrLagging if mutations after given sequence are already dropped from the log,
+// or ctx error if ctx is done.
+func (l *mutationLog[K, V]) wait(ctx context.Context, seq uint64, limit int) ([]mutation[K, V], error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if seq != l.last {
+			break
+		}
+		l.updated.Wait()
+	}
+	if seq+uint64(l.count) < l.last {
+		return nil, ErrLagging
+	}
+
+	available := int(l.last - seq)
+	if available > limit {
+		available = limit
+	}
+	offset := l.count - int(l.last-seq)
+	batch := make([]mutation[K, V], available)
+	for i := range batch {
+		batch[i] = l.entries[(l.start+offset+i)%len(l.entries)]
+	}
+	return batch, nil
+}
+
+// wakeOnDone wakes waiting readers when ctx is done. Returned function should be called to release resources.
+func (l *mutationLog[K, V]) wakeOnDone(ctx context.Context) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			l.lock.Lock()
+			l.updated.Broadcast()
+			l.lock.Unlock()
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
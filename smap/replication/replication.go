@@ -0,0 +1,75 @@
+// Package replication streams mutations of smap.Generic leader to followers,
+// so hot-standby replicas could track the leader map.
+//
+// Leader assigns sequence number to each mutation and keeps the latest mutations in bounded log.
+// Each leader gets random epoch on creation, so sequences of restarted leader are not confused with previous ones.
+// Follower sends epoch and sequence of the last applied mutation on connect: if epoch matches and the log still has
+// all following mutations, leader resumes streaming from it, otherwise it sends full snapshot of each shard first.
+// Messages are encoded with encoding/gob, so keys and values should be gob-encodable.
+package replication
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrLagging is returned by Leader when follower is so far behind, that the mutations it needs are already
+// dropped from the log. Follower should reconnect to get full sync.
+var ErrLagging = errors.New("replication: follower is lagging behind the mutation log")
+
+// DefaultLogSize is count of mutations, kept by Leader for resuming, if log size is not positive.
+const DefaultLogSize = 64 * 1024
+
+// batchSize is max count of mutations, read from the log at once.
+const batchSize = 256
+
+const (
+	kindMutation uint8 = iota
+	kindReset
+	kindSnapshot
+	kindSnapshotDone
+	kindHeader
+)
+
+// hello is sent by follower on connect.
+type hello struct {
+	Epoch   uint64
+	LastSeq uint64
+}
+
+// message is wire representation of stream message. Fields are exported for encoding/gob.
+//
+//   - kindHeader: Epoch of the leader, it starts each stream.
+//   - kindMutation: Seq, Shard, Delete, Key and Value (if not Delete).
+//   - kindReset: follower should clear its map before applying snapshots.
+//   - kindSnapshot: Shard entries, and Seq of the last mutation included in the snapshot.
+//   - kindSnapshotDone: Seq to continue from, it's the least Seq of shard snapshots.
+type message[K comparable, V any] struct {
+	Kind    uint8
+	Epoch   uint64
+	Seq     uint64
+	Shard   int
+	Delete  bool
+	Key     K
+	Value   V
+	Entries []entry[K, V]
+}
+
+type entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// newEpoch returns random non-zero epoch. crypto/rand is used, since math/rand sequence is the same in each process.
+func newEpoch() uint64 {
+	var buf [8]byte
+	for {
+		if _, err := rand.Read(buf[:]); err != nil {
+			panic(err)
+		}
+		if epoch := binary.LittleEndian.Uint64(buf[:]); epoch != 0 {
+			return epoch
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap"
+	"github.com/lispad/go-generics-tools/smap/smaptest"
+)
+
+func TestLeader_Conformance(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return NewLeader(smap.NewInteger[int, int](16, 0), 1024)
+	}, smaptest.Ints())
+}
+
+func TestReplication_FullSyncAndStream(t *testing.T) {
+	leader := NewLeader(smap.NewInteger[int, string](16, 64), 1024)
+	for i := 0; i < 1000; i++ {
+		leader.Store(i, "initial")
+	}
+	// follower could have different shards layout
+	follower := NewFollower(smap.NewInteger[int, string](4, 0))
+
+	disconnect := connect(leader, follower)
+	defer disconnect()
+	waitSynced(t, leader, follower)
+	assert.Equal(t, 1, follower.FullSyncs())
+	assert.Equal(t, entries(leader.Map()), entries(follower.Map()))
+
+	leader.Store(1, "updated")
+	leader.Delete(2)
+	_, _ = leader.LoadAndDelete(3)
+	_, _ = leader.LoadOrCreate(1000, func() string {
+		return "created"
+	})
+	waitSynced(t, leader, follower)
+	assert.Equal(t, entries(leader.Map()), entries(follower.Map()))
+	value, _ := follower.Map().Load(1)
+	assert.Equal(t, "updated", value)
+	_, ok := follower.Map().Load(2)
+	assert.False(t, ok)
+}
+
+func TestReplication_Resume(t *testing.T) {
+	leader := NewLeader(smap.NewInteger[int, int](16, 64), 1024)
+	follower := NewFollower(smap.NewInteger[int, int](16, 64))
+	for i := 0; i < 100; i++ {
+		leader.Store(i, i)
+	}
+
+	disconnect := connect(leader, follower)
+	waitSynced(t, leader, follower)
+	disconnect()
+
+	for i := 0; i < 100; i++ {
+		leader.Store(i, -i)
+		leader.Delete(i + 50)
+	}
+	assert.NotEqual(t, entries(leader.Map()), entries(follower.Map()))
+
+	disconnect = connect(leader, follower)
+	defer disconnect()
+	waitSynced(t, leader, follower)
+	assert.Equal(t, 1, follower.FullSyncs(), "follower should resume without full sync")
+	assert.Equal(t, entries(leader.Map()), entries(follower.Map()))
+}
+
+func TestReplication_LaggingFollowerGetsFullSync(t *testing.T) {
+	leader := NewLeader(smap.NewInteger[int, int](16, 64), 16)
+	follower := NewFollower(smap.NewInteger[int, int](16, 64))
+
+	disconnect := connect(leader, follower)
+	leader.Store(1, 1)
+	waitSynced(t, leader, follower)
+	disconnect()
+
+	for i := 0; i < 100; i++ {
+		leader.Store(i, i)
+	}
+	_, err := leader.log.wait(context.Background(), follower.LastSeq(), batchSize)
+	assert.ErrorIs(t, err, ErrLagging)
+
+	disconnect = connect(leader, follower)
+	defer disconnect()
+	waitSynced(t, leader, follower)
+	assert.Equal(t, 2, follower.FullSyncs())
+	assert.Equal(t, entries(leader.Map()), entries(follower.Map()))
+}
+
+func TestReplication_LeaderRestart(t *testing.T) {
+	leader := NewLeader(smap.NewInteger[int, int](16, 64), 1024)
+	follower := NewFollower(smap.NewInteger[int, int](16, 64))
+	for i := 0; i < 100; i++ {
+		leader.Store(i, i)
+	}
+
+	disconnect := connect(leader, follower)
+	waitSynced(t, leader, follower)
+	disconnect()
+	assert.Equal(t, leader.Epoch(), follower.Epoch())
+
+	// restarted leader starts sequences from scratch, and reaches follower sequence with different mutations
+	restarted := NewLeader(smap.NewInteger[int, int](16, 64), 1024)
+	for i := 0; i < 150; i++ {
+		restarted.Store(i+1000, -i)
+	}
+	assert.NotEqual(t, leader.Epoch(), restarted.Epoch())
+	assert.True(t, restarted.log.canResume(follower.LastSeq()))
+
+	disconnect = connect(restarted, follower)
+	defer disconnect()
+	waitSynced(t, restarted, follower)
+	assert.Equal(t, 2, follower.FullSyncs(), "follower should not resume from sequence of another leader")
+	assert.Equal(t, restarted.Epoch(), follower.Epoch())
+	assert.Equal(t, entries(restarted.Map()), entries(follower.Map()))
+}
+
+func TestReplication_EpochMismatch(t *testing.T) {
+	leader := NewLeader(smap.NewInteger[int, int](4, 0), 0)
+	other := NewLeader(smap.NewInteger[int, int](4, 0), 0)
+	follower := NewFollower(smap.NewInteger[int, int](4, 0))
+	for i := 0; i < 10; i++ {
+		leader.Store(i, i)
+		other.Store(i, -i)
+	}
+
+	var buf bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_ = leader.WriteTo(ctx, &buf, 0, 0)
+	assert.NoError(t, follower.Apply(&buf))
+	assert.Equal(t, leader.Epoch(), follower.Epoch())
+
+	// stream resumed with wrong epoch is rejected, instead of mixing mutations of different leaders
+	buf.Reset()
+	enc := gob.NewEncoder(&buf)
+	assert.NoError(t, enc.Encode(&message[int, int]{Kind: kindHeader, Epoch: other.Epoch()}))
+	assert.NoError(t, enc.Encode(&message[int, int]{Kind: kindMutation, Epoch: other.Epoch(), Seq: follower.LastSeq() + 1, Key: 1, Value: 1}))
+	assert.Error(t, follower.Apply(&buf))
+	assert.Equal(t, entries(leader.Map()), entries(follower.Map()))
+}
+
+func TestReplication_ConcurrentWritesDuringSync(t *testing.T) {
+	leader := NewLeader(smap.NewInteger[int, int](16, 64), 1024*1024)
+	follower := NewFollower(smap.NewInteger[int, int](8, 64))
+	for i := 0; i < 10000; i++ {
+		leader.Store(i, i)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(seed))
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := rnd.Intn(20000)
+				if rnd.Intn(3) == 0 {
+					leader.Delete(key)
+				} else {
+					leader.Store(key, rnd.Int())
+				}
+			}
+		}(int64(g))
+	}
+
+	disconnect := connect(leader, follower)
+	defer disconnect()
+	time.Sleep(50 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	waitSynced(t, leader, follower)
+	assert.Equal(t, entries(leader.Map()), entries(follower.Map()))
+}
+
+func TestReplication_WriteToApply(t *testing.T) {
+	leader := NewLeader(smap.NewInteger[int, int](4, 0), 0)
+	for i := 0; i < 100; i++ {
+		leader.Store(i, i)
+	}
+
+	var buf bytes.Buffer
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	err := leader.WriteTo(ctx, &buf, 0, 0)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	follower := NewFollower(smap.NewInteger[int, int](4, 0))
+	assert.NoError(t, follower.Apply(&buf))
+	assert.Equal(t, leader.LastSeq(), follower.LastSeq())
+	assert.Equal(t, entries(leader.Map()), entries(follower.Map()))
+}
+
+// connect starts streaming from leader to follower over net.Pipe, and returns function which stops it.
+func connect[K comparable, V any](leader Leader[K, V], follower Follower[K, V]) func() {
+	server, client := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_ = leader.Serve(ctx, server)
+		_ = server.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		_ = follower.Sync(client)
+		_ = client.Close()
+	}()
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func waitSynced[K comparable, V any](t *testing.T, leader Leader[K, V], follower Follower[K, V]) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		return follower.LastSeq() == leader.LastSeq() && follower.FullSyncs() > 0
+	}, 5*time.Second, time.Millisecond)
+}
+
+func entries[K comparable, V any](m smap.Generic[K, V]) map[K]V {
+	result := make(map[K]V)
+	m.Range(func(k K, v V) bool {
+		result[k] = v
+		return true
+	})
+	return result
+}
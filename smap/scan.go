@@ -0,0 +1,184 @@
+package smap
+
+import (
+	"math/bits"
+
+	"github.com/lispad/go-generics-tools/binheap"
+)
+
+const (
+	// scanGroupBits is count of cursor bits, used for position in Swiss shard, shard id is kept in higher bits.
+	scanGroupBits = 32
+
+	// scanDefaultCount is used, when count passed to Scan is not positive.
+	scanDefaultCount = 10
+)
+
+// Entry is key and value pair.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// Scan incrementally iterates over the map, like Redis SCAN does. Iteration starts with zero cursor,
+// and each call returns about count entries and cursor for the next call. Iteration is complete when
+// returned cursor is zero. Scan is stateless: cursor is all that should be kept between calls.
+//
+// Go maps have no stable iteration order, so keys of each shard are ordered by hash: cursor keeps shard id
+// and the lowest hash, which is not returned yet. hash should be the same for all calls of the iteration.
+// Every key present in the map for the whole iteration is returned at least once, keys added or deleted
+// during iteration may or may not be returned.
+//
+// Each call hashes all keys of single shard to select ones with the lowest hashes, so it takes O(shard length) time,
+// but shard read lock is released and taken again after every count keys, so writers are not blocked for longer.
+// Go map iteration is allowed to continue after the map is modified, and keys present for the whole iteration
+// are still visited once. Returned values are ones seen when keys were visited. Keys with equal hash are returned
+// by the same call, so with hash collisions call could return more than count entries.
+func (sm Generic[K, V]) Scan(cursor uint64, count int, hash func(key K) uint64) ([]Entry[K, V], uint64) {
+	if count <= 0 {
+		count = scanDefaultCount
+	}
+	// hash bits, which don't fit to cursor with shard id, are dropped
+	shardBits := bits.Len(uint(len(sm.shards) - 1))
+	posBits := 64 - shardBits
+	shardID := 0
+	if shardBits > 0 {
+		shardID = int(cursor >> posBits)
+	}
+	if shardID >= len(sm.shards) {
+		return nil, 0
+	}
+	from := cursor & (1<<posBits - 1)
+	position := func(key K) uint64 {
+		return hash(key) >> shardBits
+	}
+
+	type positioned struct {
+		pos   uint64
+		entry Entry[K, V]
+	}
+	// count+1 entries with the lowest positions are selected: the last one is not returned, its position is
+	// the next cursor. Top of the heap is entry with the highest position, so it's replaced with lower ones.
+	lowest := binheap.EmptyHeap[positioned](func(x, y positioned) bool {
+		return x.pos > y.pos
+	})
+	sm.rangeShardChunked(shardID, count, func(key K, value V) {
+		pos := position(key)
+		switch {
+		case pos < from:
+		case lowest.Len() <= count:
+			lowest.Push(positioned{pos: pos, entry: Entry[K, V]{Key: key, Value: value}})
+		case pos < lowest.Peak().pos:
+			lowest.Replace(positioned{pos: pos, entry: Entry[K, V]{Key: key, Value: value}})
+		}
+	})
+
+	complete := lowest.Len() <= count
+	var next uint64
+	if !complete {
+		next = lowest.Peak().pos
+	}
+	entries := make([]Entry[K, V], 0, lowest.Len())
+	for lowest.Len() > 0 {
+		if e := lowest.Pop(); complete || e.pos < next {
+			entries = append(entries, e.entry)
+		}
+	}
+	if !complete && len(entries) == 0 {
+		// all selected keys have the same position, so all keys with it are returned at once
+		sm.rangeShardChunked(shardID, count, func(key K, value V) {
+			if position(key) == next {
+				entries = append(entries, Entry[K, V]{Key: key, Value: value})
+			}
+		})
+		next++
+		complete = next&(1<<posBits-1) == 0 // position overflow
+	}
+
+	switch {
+	case !complete:
+		return entries, uint64(shardID)<<posBits | next
+	case shardID+1 < len(sm.shards):
+		return entries, uint64(shardID+1) << posBits
+	default:
+		return entries, 0
+	}
+}
+
+// rangeShardChunked calls cb for each entry of the shard, holding shard read lock for at most chunk entries.
+// Shard map could be modified or replaced by compaction, when lock is released: iteration continues over
+// the map it started with, which is allowed for Go maps.
+func (sm Generic[K, V]) rangeShardChunked(id, chunk int, cb func(K, V)) {
+	sm.locks[id].RLock()
+	visited := 0
+	for key, value := range sm.shards[id] {
+		cb(key, value)
+		if visited++; visited%chunk == 0 {
+			sm.locks[id].RUnlock()
+			sm.locks[id].RLock()
+		}
+	}
+	sm.locks[id].RUnlock()
+}
+
+// Scan incrementally iterates over the map, like Redis SCAN does. Iteration starts with zero cursor,
+// and each call returns about count entries and cursor for the next call. Iteration is complete when
+// returned cursor is zero. Scan is stateless: cursor is all that should be kept between calls.
+//
+// Cursor keeps shard id and position of home group (the first group in the key probe sequence), which is
+// incremented in reversed bit order, like Redis does: when table grows, home groups of already visited keys
+// are visited again, so every key present in the map for the whole iteration is returned at least once,
+// though keys could be returned several times. Keys added or deleted during iteration may or may not be returned.
+//
+// Each call holds read lock of single shard, and visits at most count home groups, returning all their entries,
+// so it could return fewer entries, even none, before iteration is complete, or more than count entries,
+// if many keys have the same home group.
+func (sm Swiss[K, V]) Scan(cursor uint64, count int) ([]Entry[K, V], uint64) {
+	if count <= 0 {
+		count = scanDefaultCount
+	}
+	shardID := int(cursor >> scanGroupBits)
+	if shardID >= len(sm.tables) {
+		return nil, 0
+	}
+	group := cursor & (1<<scanGroupBits - 1)
+
+	entries := make([]Entry[K, V], 0, count)
+	sm.locks[shardID].RLock()
+	t := &sm.tables[shardID]
+	for visited := 0; visited < count && len(entries) < count; visited++ {
+		entries = t.appendHomeGroup(entries, group&t.mask)
+		// reversed bits increment: higher bits of the group are incremented first
+		group = bits.Reverse64(bits.Reverse64(group|^t.mask) + 1)
+		if group == 0 {
+			break
+		}
+	}
+	sm.locks[shardID].RUnlock()
+
+	switch {
+	case group != 0:
+		return entries, uint64(shardID)<<scanGroupBits | group
+	case shardID+1 < len(sm.tables):
+		return entries, uint64(shardID+1) << scanGroupBits
+	default:
+		return entries, 0
+	}
+}
+
+// appendHomeGroup appends entries, which probe sequence starts from given group. Such entries are placed
+// in groups of the probe sequence before the first group with empty slot, like find expects.
+func (t *swissTable[K, V]) appendHomeGroup(entries []Entry[K, V], home uint64) []Entry[K, V] {
+	group := home
+	for step := uint64(1); ; step++ {
+		for i := int(group) * swissGroupSize; i < int(group+1)*swissGroupSize; i++ {
+			if t.ctrl[i]&swissEmpty == 0 && (t.hash(t.slots[i].key)>>7)&t.mask == home {
+				entries = append(entries, Entry[K, V]{Key: t.slots[i].key, Value: t.slots[i].value})
+			}
+		}
+		if swissMatchEmpty(t.group(group)) != 0 {
+			return entries
+		}
+		group = (group + step) & t.mask
+	}
+}
@@ -0,0 +1,277 @@
+package smap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwiss_Scan(t *testing.T) {
+	m := NewSwiss[int, int](8, 0, intHash)
+	for i := 0; i < 10000; i++ {
+		m.Store(i, i*i)
+	}
+
+	seen := make(map[int]int, 10000)
+	cursor, calls := uint64(0), 0
+	for {
+		var entries []Entry[int, int]
+		entries, cursor = m.Scan(cursor, 100)
+		calls++
+		for _, e := range entries {
+			assert.Equal(t, e.Key*e.Key, e.Value)
+			seen[e.Key]++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Len(t, seen, 10000)
+	for key, count := range seen {
+		assert.Equal(t, 1, count, "key %d returned several times without rehash", key)
+	}
+	assert.Greater(t, calls, 50)
+
+	entries, cursor := NewSwiss[int, int](4, 0, intHash).Scan(0, 10)
+	assert.Empty(t, entries)
+	assert.Equal(t, uint64(1)<<scanGroupBits, cursor, "empty shard is skipped in one call")
+}
+
+func TestSwiss_ScanDuringGrowth(t *testing.T) {
+	m := NewSwiss[int, int](1, 0, intHash)
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+
+	seen := make(map[int]int)
+	entries, cursor := m.Scan(0, 10)
+	for _, e := range entries {
+		seen[e.Key]++
+	}
+	// table grows several times, and entries are moved to other slots
+	for i := 100; i < 10000; i++ {
+		m.Store(i, i)
+	}
+	for cursor != 0 {
+		entries, cursor = m.Scan(cursor, 10)
+		for _, e := range entries {
+			seen[e.Key]++
+		}
+	}
+	for i := 0; i < 100; i++ {
+		assert.GreaterOrEqual(t, seen[i], 1, "key %d is missing", i)
+	}
+}
+
+func TestSwiss_ScanCollidingHashes(t *testing.T) {
+	m := NewSwiss[int, int](1, 0, func(int) uint64 {
+		return 42
+	})
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	// all keys have the same home group, so they are returned at once
+	entries, cursor := m.Scan(0, 10)
+	assert.Len(t, entries, 100)
+	for cursor != 0 {
+		entries, cursor = m.Scan(cursor, 10)
+		assert.Empty(t, entries)
+	}
+}
+
+func TestSwiss_ScanConcurrentModification(t *testing.T) {
+	m := NewSwiss[int, int](4, 0, intHash)
+	// stable keys are present for the whole scan, other keys are added and deleted concurrently
+	for i := 0; i < 5000; i++ {
+		m.Store(i*2, i)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10000; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			key := (i%20000)*2 + 1
+			if i%3 == 0 {
+				m.Delete(key)
+			} else {
+				m.Store(key, i)
+			}
+		}
+	}()
+
+	seen := make(map[int]bool)
+	cursor := uint64(0)
+	for {
+		var entries []Entry[int, int]
+		entries, cursor = m.Scan(cursor, 16)
+		for _, e := range entries {
+			seen[e.Key] = true
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	for i := 0; i < 5000; i++ {
+		assert.True(t, seen[i*2], "stable key %d is missing", i*2)
+	}
+}
+
+func scanGeneric(m Generic[int, int], count int, hash func(int) uint64, between func()) map[int]int {
+	seen := make(map[int]int)
+	cursor := uint64(0)
+	for {
+		var entries []Entry[int, int]
+		entries, cursor = m.Scan(cursor, count, hash)
+		for _, e := range entries {
+			seen[e.Key]++
+		}
+		if cursor == 0 {
+			return seen
+		}
+		if between != nil {
+			between()
+		}
+	}
+}
+
+func TestGeneric_Scan(t *testing.T) {
+	for _, shards := range []int{1, 3, 16} {
+		m := NewInteger[int, int](shards, 0)
+		for i := 0; i < 10000; i++ {
+			m.Store(i, i*i)
+		}
+
+		calls := 0
+		seen := scanGeneric(m, 100, intHash, func() {
+			calls++
+		})
+		assert.Len(t, seen, 10000)
+		for key, count := range seen {
+			assert.Equal(t, 1, count, "key %d returned several times", key)
+		}
+		assert.GreaterOrEqual(t, calls, 99)
+		assert.LessOrEqual(t, calls, 100+shards)
+
+		entries, _ := m.Scan(0, 10, intHash)
+		assert.Len(t, entries, 10)
+		for _, e := range entries {
+			assert.Equal(t, e.Key*e.Key, e.Value)
+		}
+	}
+
+	entries, cursor := NewInteger[int, int](4, 0).Scan(0, 10, intHash)
+	assert.Empty(t, entries)
+	assert.Equal(t, uint64(1)<<62, cursor, "empty shard is skipped in one call")
+}
+
+func TestGeneric_ScanCollidingHashes(t *testing.T) {
+	m := NewInteger[int, int](2, 0)
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	// even keys have the same hash, so they are returned at once
+	seen := make(map[int]int)
+	cursor, calls := uint64(0), 0
+	for {
+		var entries []Entry[int, int]
+		entries, cursor = m.Scan(cursor, 10, func(key int) uint64 {
+			if key%2 == 0 {
+				return 1 << 40
+			}
+			return intHash(key)
+		})
+		calls++
+		for _, e := range entries {
+			seen[e.Key]++
+		}
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Len(t, seen, 100)
+	for key, count := range seen {
+		assert.Equal(t, 1, count, "key %d returned several times", key)
+	}
+	assert.Less(t, calls, 20)
+}
+
+func TestGeneric_ScanConcurrentModification(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	// stable keys are present for the whole scan, other keys are added and deleted between calls
+	for i := 0; i < 5000; i++ {
+		m.Store(i*2, i)
+	}
+
+	i := 0
+	seen := scanGeneric(m, 16, intHash, func() {
+		for j := 0; j < 100; j++ {
+			i++
+			key := (i%20000)*2 + 1
+			if i%3 == 0 {
+				m.Delete(key)
+			} else {
+				m.Store(key, i)
+			}
+		}
+	})
+	for i := 0; i < 5000; i++ {
+		assert.Equal(t, 1, seen[i*2], "stable key %d should be returned once", i*2)
+	}
+}
+
+func TestGeneric_ScanLocksChunks(t *testing.T) {
+	m := NewInteger[int, int](1, 0, WithStats())
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	reads := m.Stats().Reads
+	entries, _ := m.Scan(0, 10, intHash)
+	assert.Len(t, entries, 10)
+	assert.Equal(t, reads+101, m.Stats().Reads, "read lock is taken again after every 10 keys")
+}
+
+func TestGeneric_ScanConcurrentWriter(t *testing.T) {
+	m := NewInteger[int, int](2, 0, WithAutoCompaction(0.1))
+	for i := 0; i < 5000; i++ {
+		m.Store(i*2, i)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// other keys are added and deleted during calls, shards are compacted
+		for i := 0; i < 10000; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			key := (i%20000)*2 + 1
+			if i%2 == 0 {
+				m.Delete(key)
+			} else {
+				m.Store(key, i)
+			}
+		}
+	}()
+	seen := scanGeneric(m, 16, intHash, func() {})
+	close(done)
+	wg.Wait()
+
+	for i := 0; i < 5000; i++ {
+		assert.Equal(t, 1, seen[i*2], "stable key %d should be returned once", i*2)
+	}
+}
@@ -0,0 +1,103 @@
+package smap
+
+import "fmt"
+
+// ShardReader gives read access to entries of single shard, while its read lock is held.
+// It's valid only inside WithShardRLocked or WithShardLocked callback, and should not be retained.
+// Methods panic if key belongs to other shard.
+type ShardReader[K comparable, V any] struct {
+	sm Generic[K, V]
+	id int
+}
+
+// ShardWriter gives read and write access to entries of single shard, while its write lock is held.
+// It's valid only inside WithShardLocked or WithKeyShard callback, and should not be retained.
+// Methods panic if key belongs to other shard.
+type ShardWriter[K comparable, V any] struct {
+	ShardReader[K, V]
+}
+
+// WithShardRLocked calls fn with reader of shard with given id, holding shard read lock.
+// fn should not call methods of sm for keys from the same shard.
+func (sm Generic[K, V]) WithShardRLocked(id int, fn func(s ShardReader[K, V])) {
+	sm.locks[id].RLock()
+	defer sm.locks[id].RUnlock()
+	fn(ShardReader[K, V]{sm: sm, id: id})
+}
+
+// WithShardLocked calls fn with writer of shard with given id, holding shard write lock.
+// It's safe replacement of LockShard with Unblocked* methods: several entries of the shard could be read and
+// changed atomically. fn should not call methods of sm for keys from the same shard.
+func (sm Generic[K, V]) WithShardLocked(id int, fn func(s ShardWriter[K, V])) {
+	sm.locks[id].Lock()
+	defer sm.locks[id].Unlock()
+	fn(ShardWriter[K, V]{ShardReader[K, V]{sm: sm, id: id}})
+	// auto compaction is postponed until fn returns, so shard map is not replaced during Range
+	sm.deleted(id, 0)
+}
+
+// WithKeyShard calls fn with writer of the shard, containing key, holding shard write lock. See WithShardLocked.
+func (sm Generic[K, V]) WithKeyShard(key K, fn func(s ShardWriter[K, V])) {
+	sm.WithShardLocked(sm.shardDetector(key), fn)
+}
+
+// ID returns shard id.
+func (s ShardReader[K, V]) ID() int {
+	return s.id
+}
+
+// Get returns the value stored in the shard for a key.
+// The ok result indicates whether value was found.
+func (s ShardReader[K, V]) Get(key K) (V, bool) {
+	s.checkKey(key)
+	s.sm.checkLocked("ShardReader.Get", s.id, false)
+	value, ok := s.sm.shards[s.id][key]
+	return value, ok
+}
+
+// Range calls cb sequentially for each key and value present in the shard.
+// If cb returns false, range stops the iteration. Entries could be changed with ShardWriter from cb.
+func (s ShardReader[K, V]) Range(cb func(K, V) bool) {
+	s.sm.checkLocked("ShardReader.Range", s.id, false)
+	for key, value := range s.sm.shards[s.id] {
+		if !cb(key, value) {
+			break
+		}
+	}
+}
+
+// Len returns count of entries in the shard.
+func (s ShardReader[K, V]) Len() int {
+	s.sm.checkLocked("ShardReader.Len", s.id, false)
+	return len(s.sm.shards[s.id])
+}
+
+// Set sets the value for a key.
+func (s ShardWriter[K, V]) Set(key K, value V) {
+	s.checkKey(key)
+	s.sm.checkLocked("ShardWriter.Set", s.id, true)
+	s.sm.storing(s.id, key)
+	s.sm.shards[s.id][key] = value
+}
+
+// Delete deletes the value for a key. The ok result reports whether the key was present.
+func (s ShardWriter[K, V]) Delete(key K) bool {
+	s.checkKey(key)
+	s.sm.checkLocked("ShardWriter.Delete", s.id, true)
+	if _, ok := s.sm.shards[s.id][key]; !ok {
+		return false
+	}
+	delete(s.sm.shards[s.id], key)
+	s.sm.removed(s.id, key)
+	if s.sm.deletions != nil {
+		s.sm.deletions[s.id]++
+	}
+	return true
+}
+
+// checkKey panics if key belongs to other shard.
+func (s ShardReader[K, V]) checkKey(key K) {
+	if id := s.sm.shardDetector(key); id != s.id {
+		panic(fmt.Sprintf("smap: key %v belongs to shard %d, not to shard %d of the handle", key, id, s.id))
+	}
+}
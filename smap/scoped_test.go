@@ -0,0 +1,102 @@
+package smap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneric_WithShardLocked(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+
+	// move value between two keys of the same shard atomically
+	m.WithKeyShard(1, func(s ShardWriter[int, int]) {
+		assert.Equal(t, 1, s.ID())
+		v, ok := s.Get(1)
+		assert.True(t, ok)
+		s.Set(5, v)
+		assert.True(t, s.Delete(1))
+		assert.False(t, s.Delete(1))
+	})
+	_, ok := m.Load(1)
+	assert.False(t, ok)
+	v, _ := m.Load(5)
+	assert.Equal(t, 1, v)
+
+	// entries could be changed during Range
+	m.WithShardLocked(2, func(s ShardWriter[int, int]) {
+		assert.Equal(t, 25, s.Len())
+		s.Range(func(k, v int) bool {
+			if k%4 == 2 && k%8 == 2 {
+				s.Delete(k)
+			} else {
+				s.Set(k, -v)
+			}
+			return true
+		})
+		assert.Equal(t, 12, s.Len())
+	})
+	for i := 2; i < 100; i += 4 {
+		v, ok := m.Load(i)
+		assert.Equal(t, i%8 != 2, ok)
+		if ok {
+			assert.Equal(t, -i, v)
+		}
+	}
+}
+
+func TestGeneric_WithShardRLocked(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+
+	sum := 0
+	m.WithShardRLocked(3, func(s ShardReader[int, int]) {
+		s.Range(func(k, v int) bool {
+			sum += v
+			return true
+		})
+		v, ok := s.Get(7)
+		assert.True(t, ok)
+		assert.Equal(t, 7, v)
+	})
+	assert.Equal(t, 25*(3+99)/2, sum)
+}
+
+func TestGeneric_WithShardLockedWrongKey(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	assert.PanicsWithValue(t, "smap: key 1 belongs to shard 1, not to shard 0 of the handle", func() {
+		m.WithShardLocked(0, func(s ShardWriter[int, int]) {
+			s.Set(1, 1)
+		})
+	})
+	assert.PanicsWithValue(t, "smap: key 6 belongs to shard 2, not to shard 0 of the handle", func() {
+		m.WithShardRLocked(0, func(s ShardReader[int, int]) {
+			s.Get(6)
+		})
+	})
+	// lock is released after panic
+	m.Store(1, 1)
+	m.Store(4, 4)
+}
+
+func TestGeneric_WithShardLockedAutoCompaction(t *testing.T) {
+	m := NewInteger[int, int](1, 0, WithAutoCompaction(0.5))
+	for i := 0; i < 1000; i++ {
+		m.Store(i, i)
+	}
+	m.WithShardLocked(0, func(s ShardWriter[int, int]) {
+		s.Range(func(k, v int) bool {
+			if k%10 != 0 {
+				s.Delete(k)
+			}
+			return true
+		})
+		assert.Equal(t, 100, s.Len())
+	})
+	assert.Equal(t, 0, m.deletions[0], "shard should be compacted after callback")
+}
@@ -0,0 +1,71 @@
+package shard
+
+import (
+	"math"
+
+	"golang.org/x/exp/constraints"
+)
+
+// fibonacciMultiplier is 2^64 divided by golden ratio.
+const fibonacciMultiplier = 11400714819323198485
+
+// Modulo returns detector, that uses remainder of key division by shards count.
+// Unlike int(key) % shardsCount, negative keys are handled correctly.
+// Keys, that share a stride with shards count, are clustered in the same shards, Fibonacci could be used instead.
+func Modulo[K constraints.Integer](shardsCount int) (func(key K) int, error) {
+	if shardsCount <= 0 {
+		return nil, ErrInvalidShardsCount
+	}
+	count := uint64(shardsCount)
+	return func(key K) int {
+		return int(uint64(key) % count)
+	}, nil
+}
+
+// Mask returns detector, that uses low bits of key. It's the fastest detector,
+// but requires shards count to be power of two, and works well only for keys with uniform low bits.
+func Mask[K constraints.Integer](shardsCount int) (func(key K) int, error) {
+	if shardsCount <= 0 {
+		return nil, ErrInvalidShardsCount
+	}
+	if shardsCount&(shardsCount-1) != 0 {
+		return nil, ErrNotPowerOfTwo
+	}
+	mask := uint64(shardsCount - 1)
+	return func(key K) int {
+		return int(uint64(key) & mask)
+	}, nil
+}
+
+// Fibonacci returns detector, based on Fibonacci multiplicative hashing: key is multiplied by 2^64/phi,
+// and high bits of product are used. Sequential keys, and keys with common stride are spread evenly over shards.
+func Fibonacci[K constraints.Integer](shardsCount int) (func(key K) int, error) {
+	return FromHash(shardsCount, func(key K) uint64 {
+		return uint64(key) * fibonacciMultiplier
+	})
+}
+
+// JumpHash returns detector, based on jump consistent hash by Lamping and Veach.
+// When shards count is changed from n to n+1, only 1/(n+1) of keys are moved, all of them to the new shard.
+// Detection takes O(ln(shardsCount)) time.
+func JumpHash[K constraints.Integer](shardsCount int) (func(key K) int, error) {
+	if shardsCount <= 0 {
+		return nil, ErrInvalidShardsCount
+	}
+	if shardsCount > math.MaxInt32 {
+		return nil, ErrInvalidShardsCount
+	}
+	return func(key K) int {
+		return jump(uint64(key), shardsCount)
+	}, nil
+}
+
+func jump(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,43 @@
+// Package shard provides shard detectors for smap maps.
+// Each constructor validates shards count, and returns detector, that is guaranteed to return shard id
+// in [0, shardsCount) range for any key.
+package shard
+
+import (
+	"errors"
+	"math/bits"
+)
+
+var (
+	// ErrInvalidShardsCount is returned when shards count is not positive.
+	ErrInvalidShardsCount = errors.New("shard: shards count should be positive")
+	// ErrNotPowerOfTwo is returned by detectors, that require shards count to be power of two.
+	ErrNotPowerOfTwo = errors.New("shard: shards count should be power of two")
+)
+
+// Must returns detector, or panics if err is not nil.
+// Could be used for detectors with constant shards count.
+func Must[K any](detector func(key K) int, err error) func(key K) int {
+	if err != nil {
+		panic(err)
+	}
+	return detector
+}
+
+// FromHash returns detector, that maps 64-bit key hash to shards range with multiply-shift (Lemire's fast range).
+// High bits of hash are used, so hash function should mix them well.
+func FromHash[K any](shardsCount int, hash func(key K) uint64) (func(key K) int, error) {
+	if shardsCount <= 0 {
+		return nil, ErrInvalidShardsCount
+	}
+	count := uint64(shardsCount)
+	return func(key K) int {
+		return reduce(hash(key), count)
+	}, nil
+}
+
+// reduce maps hash to [0, count) range without division.
+func reduce(hash, count uint64) int {
+	hi, _ := bits.Mul64(hash, count)
+	return int(hi)
+}
@@ -0,0 +1,122 @@
+package shard_test
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+func TestIntegerDetectors_Range(t *testing.T) {
+	constructors := map[string]func(int) (func(int64) int, error){
+		"Modulo":    shard.Modulo[int64],
+		"Mask":      shard.Mask[int64],
+		"Fibonacci": shard.Fibonacci[int64],
+		"JumpHash":  shard.JumpHash[int64],
+	}
+	keys := []int64{0, 1, -1, math.MaxInt64, math.MinInt64, 12345, -12345}
+	for i := 0; i < 1000; i++ {
+		keys = append(keys, rand.Int63()-rand.Int63())
+	}
+
+	for name, constructor := range constructors {
+		t.Run(name, func(t *testing.T) {
+			_, err := constructor(0)
+			assert.ErrorIs(t, err, shard.ErrInvalidShardsCount)
+			_, err = constructor(-8)
+			assert.ErrorIs(t, err, shard.ErrInvalidShardsCount)
+
+			for _, count := range []int{1, 2, 8, 64, 1024} {
+				detector, err := constructor(count)
+				assert.NoError(t, err)
+				for _, key := range keys {
+					id := detector(key)
+					assert.True(t, id >= 0 && id < count, "key %d, shard %d of %d", key, id, count)
+					assert.Equal(t, id, detector(key)) // idempotent
+				}
+			}
+		})
+	}
+}
+
+func TestMask_PowerOfTwo(t *testing.T) {
+	_, err := shard.Mask[int](12)
+	assert.ErrorIs(t, err, shard.ErrNotPowerOfTwo)
+	assert.Panics(t, func() {
+		shard.Must(shard.Mask[int](12))
+	})
+
+	detector := shard.Must(shard.Mask[int](16))
+	assert.Equal(t, 5, detector(21))
+	assert.Equal(t, 15, detector(-1))
+}
+
+func TestFibonacci_Stride(t *testing.T) {
+	const shardsCount = 16
+	modulo := shard.Must(shard.Modulo[uint64](shardsCount))
+	fibonacci := shard.Must(shard.Fibonacci[uint64](shardsCount))
+
+	moduloShards := make(map[int]int)
+	fibonacciShards := make(map[int]int)
+	for key := uint64(0); key < 16*1024; key += 32 { // keys share a stride with shards count
+		moduloShards[modulo(key)]++
+		fibonacciShards[fibonacci(key)]++
+	}
+	assert.Len(t, moduloShards, 1) // all keys are in the same shard
+	assert.Len(t, fibonacciShards, shardsCount)
+	for _, count := range fibonacciShards {
+		assert.InDelta(t, 512/shardsCount, count, 4)
+	}
+}
+
+func TestJumpHash_Consistency(t *testing.T) {
+	const keys = 10000
+	for _, count := range []int{1, 7, 64} {
+		before := shard.Must(shard.JumpHash[int](count))
+		after := shard.Must(shard.JumpHash[int](count + 1))
+		moved := 0
+		for key := 0; key < keys; key++ {
+			if id := after(key); id != before(key) {
+				assert.Equal(t, count, id) // keys are moved only to the new shard
+				moved++
+			}
+		}
+		assert.InDelta(t, keys/(count+1), moved, float64(keys)/float64(count+1)*0.2)
+	}
+}
+
+func TestStringDetectors(t *testing.T) {
+	_, err := shard.FNV(0)
+	assert.ErrorIs(t, err, shard.ErrInvalidShardsCount)
+	_, err = shard.XXHash(-1)
+	assert.ErrorIs(t, err, shard.ErrInvalidShardsCount)
+
+	for _, detector := range []func(string) int{shard.Must(shard.FNV(24)), shard.Must(shard.XXHash(24))} {
+		shards := make(map[int]int)
+		for i := 0; i < 24000; i++ {
+			id := detector(strconv.Itoa(i))
+			assert.True(t, id >= 0 && id < 24)
+			shards[id]++
+		}
+		for _, count := range shards {
+			assert.InDelta(t, 1000, count, 150)
+		}
+	}
+}
+
+func TestHashes(t *testing.T) {
+	assert.Equal(t, uint64(0xcbf29ce484222325), shard.FNV64a(""))
+	assert.Equal(t, uint64(0xaf63dc4c8601ec8c), shard.FNV64a("a"))
+	assert.Equal(t, uint64(0x85944171f73967e8), shard.FNV64a("foobar"))
+
+	assert.Equal(t, uint64(0xef46db3751d8e999), shard.XXHash64(""))
+	assert.Equal(t, uint64(0xd24ec4f1a98c6e5b), shard.XXHash64("a"))
+	assert.Equal(t, uint64(0x1c330fb2d66be179), shard.XXHash64("as"))
+	assert.Equal(t, uint64(0x631c37ce72a97393), shard.XXHash64("asd"))
+	assert.Equal(t, uint64(0x415872f599cea71e), shard.XXHash64("asdf"))
+	assert.Equal(t, uint64(0x02a2e85470d6fd96), shard.XXHash64("Call me Ishmael. Some years ago--never mind how long precisely-"))
+}
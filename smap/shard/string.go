@@ -0,0 +1,114 @@
+package shard
+
+import (
+	"math/bits"
+)
+
+// FNV returns detector for string keys, based on 64-bit FNV-1a hash. Hash is calculated without allocations.
+// High bits of FNV-1a are mixed poorly for short keys, so remainder of division by shards count is used.
+func FNV(shardsCount int) (func(key string) int, error) {
+	if shardsCount <= 0 {
+		return nil, ErrInvalidShardsCount
+	}
+	count := uint64(shardsCount)
+	return func(key string) int {
+		return int(FNV64a(key) % count)
+	}, nil
+}
+
+// XXHash returns detector for string keys, based on 64-bit xxHash. It's faster than FNV for long keys.
+func XXHash(shardsCount int) (func(key string) int, error) {
+	return FromHash(shardsCount, XXHash64)
+}
+
+// FNV64a returns 64-bit FNV-1a hash of key.
+func FNV64a(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	hash := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint64(key[i])
+		hash *= prime64
+	}
+	return hash
+}
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// XXHash64 returns 64-bit xxHash of key with zero seed.
+func XXHash64(key string) uint64 {
+	n := len(key)
+	i := 0
+	var hash uint64
+	if n >= 32 {
+		v1 := xxPrime1
+		v1 += xxPrime2
+		v2 := xxPrime2
+		v3 := uint64(0)
+		v4 := xxPrime1
+		v4 = -v4
+		for ; i+32 <= n; i += 32 {
+			v1 = xxRound(v1, readUint64(key, i))
+			v2 = xxRound(v2, readUint64(key, i+8))
+			v3 = xxRound(v3, readUint64(key, i+16))
+			v4 = xxRound(v4, readUint64(key, i+24))
+		}
+		hash = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		hash = xxMergeRound(hash, v1)
+		hash = xxMergeRound(hash, v2)
+		hash = xxMergeRound(hash, v3)
+		hash = xxMergeRound(hash, v4)
+	} else {
+		hash = xxPrime5
+	}
+
+	hash += uint64(n)
+	for ; i+8 <= n; i += 8 {
+		hash ^= xxRound(0, readUint64(key, i))
+		hash = bits.RotateLeft64(hash, 27)*xxPrime1 + xxPrime4
+	}
+	if i+4 <= n {
+		hash ^= uint64(readUint32(key, i)) * xxPrime1
+		hash = bits.RotateLeft64(hash, 23)*xxPrime2 + xxPrime3
+		i += 4
+	}
+	for ; i < n; i++ {
+		hash ^= uint64(key[i]) * xxPrime5
+		hash = bits.RotateLeft64(hash, 11) * xxPrime1
+	}
+
+	hash ^= hash >> 33
+	hash *= xxPrime2
+	hash ^= hash >> 29
+	hash *= xxPrime3
+	hash ^= hash >> 32
+	return hash
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc, value uint64) uint64 {
+	acc ^= xxRound(0, value)
+	return acc*xxPrime1 + xxPrime4
+}
+
+func readUint64(s string, i int) uint64 {
+	return uint64(s[i]) | uint64(s[i+1])<<8 | uint64(s[i+2])<<16 | uint64(s[i+3])<<24 |
+		uint64(s[i+4])<<32 | uint64(s[i+5])<<40 | uint64(s[i+6])<<48 | uint64(s[i+7])<<56
+}
+
+func readUint32(s string, i int) uint32 {
+	return uint32(s[i]) | uint32(s[i+1])<<8 | uint32(s[i+2])<<16 | uint32(s[i+3])<<24
+}
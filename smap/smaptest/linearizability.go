@@ -0,0 +1,93 @@
+package smaptest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// event is operation from concurrent history with its result, and logical times of call and return.
+type event struct {
+	operation
+	result
+	goroutine int
+	call      int64
+	ret       int64
+}
+
+func (e event) String() string {
+	return fmt.Sprintf("[%d..%d] goroutine %d: %s -> (%d, %t)", e.call, e.ret, e.goroutine, e.operation, e.result.value, e.result.ok)
+}
+
+// linearizable reports whether history of single key operations is linearizable, starting from the initial state.
+// Since linearizability is local property, history of the whole map is linearizable if history of each key is.
+// Wing & Gong search is used with memoization of visited (linearized set, state) pairs.
+func linearizable(history []event, initial state) bool {
+	c := checker{
+		history:    history,
+		linearized: make([]bool, len(history)),
+		visited:    make(map[string]struct{}),
+	}
+	return c.search(initial, 0)
+}
+
+type checker struct {
+	history    []event
+	linearized []bool
+	visited    map[string]struct{}
+}
+
+func (c *checker) search(s state, done int) bool {
+	if done == len(c.history) {
+		return true
+	}
+
+	// only operations called before the earliest return of pending operations could be linearized next
+	minRet := int64(-1)
+	for i, e := range c.history {
+		if !c.linearized[i] && (minRet == -1 || e.ret < minRet) {
+			minRet = e.ret
+		}
+	}
+	for i, e := range c.history {
+		if c.linearized[i] || e.call > minRet {
+			continue
+		}
+		next, ok := step(s, e.operation, e.result)
+		if !ok {
+			continue
+		}
+		c.linearized[i] = true
+		if c.visit(next) && c.search(next, done+1) {
+			return true
+		}
+		c.linearized[i] = false
+	}
+	return false
+}
+
+// visit marks current linearized set with state as visited, and reports whether it was not visited before.
+func (c *checker) visit(s state) bool {
+	var key strings.Builder
+	for _, l := range c.linearized {
+		if l {
+			key.WriteByte('1')
+		} else {
+			key.WriteByte('0')
+		}
+	}
+	fmt.Fprintf(&key, ":%d:%t", s.value, s.present)
+	if _, ok := c.visited[key.String()]; ok {
+		return false
+	}
+	c.visited[key.String()] = struct{}{}
+	return true
+}
+
+func formatHistory(history []event) string {
+	var b strings.Builder
+	for _, e := range history {
+		b.WriteString(e.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
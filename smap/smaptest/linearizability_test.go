@@ -0,0 +1,68 @@
+package smaptest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinearizable(t *testing.T) {
+	store := func(value int, call, ret int64) event {
+		return event{operation: operation{kind: opStore, value: value}, call: call, ret: ret}
+	}
+	load := func(value int, ok bool, call, ret int64) event {
+		return event{operation: operation{kind: opLoad}, result: result{value, ok}, call: call, ret: ret}
+	}
+
+	// sequential history
+	assert.True(t, linearizable([]event{store(1, 1, 2), load(1, true, 3, 4)}, state{}))
+	assert.False(t, linearizable([]event{store(1, 1, 2), load(0, false, 3, 4)}, state{}))
+
+	// concurrent load could observe either old or new value
+	assert.True(t, linearizable([]event{store(1, 1, 4), load(0, false, 2, 3)}, state{}))
+	assert.True(t, linearizable([]event{store(1, 1, 4), load(1, true, 2, 3)}, state{}))
+
+	// stale read: after load observed new value, next load could not observe the old one
+	assert.False(t, linearizable([]event{
+		store(1, 1, 10),
+		load(1, true, 2, 3),
+		load(0, false, 4, 5),
+	}, state{}))
+
+	// both loads could not be reordered with each other, but could be with concurrent stores
+	assert.True(t, linearizable([]event{
+		store(1, 1, 10),
+		store(2, 2, 11),
+		load(2, true, 3, 4),
+		load(1, true, 5, 6),
+	}, state{}))
+	assert.False(t, linearizable([]event{
+		store(1, 1, 2),
+		store(2, 3, 11),
+		load(2, true, 4, 5),
+		load(1, true, 6, 7),
+	}, state{}))
+}
+
+func TestStep(t *testing.T) {
+	empty, present := state{}, state{value: 10, present: true}
+
+	next, ok := step(empty, operation{kind: opLoadOrCreate, value: 5}, result{5, false})
+	assert.True(t, ok)
+	assert.Equal(t, state{value: 5, present: true}, next)
+	_, ok = step(present, operation{kind: opLoadOrCreate, value: 5}, result{5, false})
+	assert.False(t, ok)
+
+	next, ok = step(present, operation{kind: opLoadAndDelete}, result{10, true})
+	assert.True(t, ok)
+	assert.Equal(t, empty, next)
+
+	next, ok = step(present, operation{kind: opCompareAndSwap, old: 10, value: 11}, result{11, true})
+	assert.True(t, ok)
+	assert.Equal(t, state{value: 11, present: true}, next)
+	next, ok = step(present, operation{kind: opCompareAndSwap, old: 9, value: 11}, result{10, false})
+	assert.True(t, ok)
+	assert.Equal(t, present, next)
+	_, ok = step(empty, operation{kind: opCompareAndSwap, old: 0, value: 11}, result{11, true})
+	assert.False(t, ok)
+}
@@ -0,0 +1,111 @@
+package smaptest
+
+import (
+	"fmt"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+type opKind int
+
+const (
+	opLoad opKind = iota
+	opStore
+	opLoadAndDelete
+	opLoadOrCreate
+	opDelete
+	opCompareAndSwap
+)
+
+var opNames = [...]string{"Load", "Store", "LoadAndDelete", "LoadOrCreate", "Delete", "CompareAndSwap"}
+
+// compareAndSwapper is implemented by maps with comparable values, e.g. smap.GenericComparable.
+type compareAndSwapper[K comparable, V any] interface {
+	CompareAndSwap(key K, old, new V) (V, bool)
+}
+
+// operation is a single call of map method, with key and value indexes. For LoadOrCreate value is generator result,
+// for CompareAndSwap old is expected value, and value is the new one.
+type operation struct {
+	kind  opKind
+	key   int
+	value int
+	old   int
+}
+
+type result struct {
+	value int
+	ok    bool
+}
+
+func (o operation) String() string {
+	switch o.kind {
+	case opStore, opLoadOrCreate:
+		return fmt.Sprintf("%s(%d, %d)", opNames[o.kind], o.key, o.value)
+	case opCompareAndSwap:
+		return fmt.Sprintf("%s(%d, %d, %d)", opNames[o.kind], o.key, o.old, o.value)
+	default:
+		return fmt.Sprintf("%s(%d)", opNames[o.kind], o.key)
+	}
+}
+
+// run calls operation on the map, converting key and value indexes with codec, and returns its result.
+func run[K comparable, V comparable](m smap.Map[K, V], c *codec[K, V], o operation) result {
+	var (
+		value V
+		r     result
+	)
+	key := c.key(o.key)
+	switch o.kind {
+	case opLoad:
+		value, r.ok = m.Load(key)
+	case opStore:
+		m.Store(key, c.value(o.value))
+	case opLoadAndDelete:
+		value, r.ok = m.LoadAndDelete(key)
+	case opLoadOrCreate:
+		value, r.ok = m.LoadOrCreate(key, func() V { return c.value(o.value) })
+	case opDelete:
+		m.Delete(key)
+	case opCompareAndSwap:
+		value, r.ok = m.(compareAndSwapper[K, V]).CompareAndSwap(key, c.value(o.old), c.value(o.value))
+	}
+	r.value = c.valueIndex(value)
+	return r
+}
+
+// state is sequential model of single key.
+type state struct {
+	value   int
+	present bool
+}
+
+// step applies operation to the model, and reports whether result r is allowed for it.
+func step(s state, o operation, r result) (state, bool) {
+	switch o.kind {
+	case opLoad:
+		return s, r == expected(s)
+	case opStore:
+		return state{value: o.value, present: true}, true
+	case opLoadAndDelete:
+		return state{}, r == expected(s)
+	case opLoadOrCreate:
+		if s.present {
+			return s, r == result{value: s.value, ok: true}
+		}
+		return state{value: o.value, present: true}, r == result{value: o.value}
+	case opDelete:
+		return state{}, true
+	case opCompareAndSwap:
+		if s.present && s.value == o.old {
+			return state{value: o.value, present: true}, r == result{value: o.value, ok: true}
+		}
+		return s, r == result{value: s.value}
+	}
+	return s, false
+}
+
+// expected returns result of Load for the model state.
+func expected(s state) result {
+	return result{value: s.value, ok: s.present}
+}
@@ -0,0 +1,350 @@
+// Package smaptest provides conformance test suite for smap.Map implementations:
+// table tests for each method, randomized comparison with sequential model,
+// and concurrent histories checked for linearizability.
+package smaptest
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+const (
+	sequentialOperations = 10000
+	sequentialKeys       = 64
+
+	concurrentRounds     = 50
+	concurrentGoroutines = 4
+	concurrentOperations = 40
+	concurrentKeys       = 3
+)
+
+// Factory creates new empty map. It's called for each test case.
+type Factory[K comparable, V any] func() smap.Map[K, V]
+
+// Generator maps integer indexes to keys and values, so maps with any key and value types could be tested.
+// Key should return distinct keys for distinct indexes, including negative ones.
+// Value is called for positive indexes only, and should return distinct values, which are not zero value of V:
+// index 0 stands for zero value.
+type Generator[K comparable, V comparable] struct {
+	Key   func(i int) K
+	Value func(i int) V
+}
+
+// Ints returns generator for int keys and values, which returns index itself.
+func Ints() Generator[int, int] {
+	identity := func(i int) int { return i }
+	return Generator[int, int]{Key: identity, Value: identity}
+}
+
+// RunConformance runs conformance test suite for maps, created by factory, with keys and values from gen.
+// If map implements CompareAndSwap(key K, old, new V) (V, bool), like smap.GenericComparable does,
+// it's verified too.
+// Range is checked only in sequential tests, since it does not correspond to any consistent snapshot.
+func RunConformance[K comparable, V comparable](t *testing.T, factory Factory[K, V], gen Generator[K, V]) {
+	t.Helper()
+	c := newCodec(gen)
+	t.Run("Methods", func(t *testing.T) {
+		runMethods(t, factory, c)
+	})
+	t.Run("Range", func(t *testing.T) {
+		runRange(t, factory, c)
+	})
+	t.Run("SequentialModel", func(t *testing.T) {
+		runSequentialModel(t, factory, c)
+	})
+	t.Run("Linearizability", func(t *testing.T) {
+		runLinearizability(t, factory, c)
+	})
+}
+
+func runMethods[K comparable, V comparable](t *testing.T, factory Factory[K, V], c *codec[K, V]) {
+	tests := []struct {
+		name     string
+		initial  map[int]int
+		op       operation
+		expected result
+		final    map[int]int
+	}{
+		{"Load missing", nil, operation{kind: opLoad, key: 1}, result{}, nil},
+		{"Load present", map[int]int{1: 10}, operation{kind: opLoad, key: 1}, result{10, true}, map[int]int{1: 10}},
+		{"Load zero value", map[int]int{1: 0}, operation{kind: opLoad, key: 1}, result{0, true}, map[int]int{1: 0}},
+		{"Load other key", map[int]int{2: 20}, operation{kind: opLoad, key: 1}, result{}, map[int]int{2: 20}},
+		{"Store new", nil, operation{kind: opStore, key: 1, value: 10}, result{}, map[int]int{1: 10}},
+		{"Store overwrite", map[int]int{1: 10}, operation{kind: opStore, key: 1, value: 11}, result{}, map[int]int{1: 11}},
+		{"Store negative key", nil, operation{kind: opStore, key: -1, value: 10}, result{}, map[int]int{-1: 10}},
+		{"LoadAndDelete missing", map[int]int{2: 20}, operation{kind: opLoadAndDelete, key: 1}, result{}, map[int]int{2: 20}},
+		{"LoadAndDelete present", map[int]int{1: 10, 2: 20}, operation{kind: opLoadAndDelete, key: 1}, result{10, true}, map[int]int{2: 20}},
+		{"LoadOrCreate missing", nil, operation{kind: opLoadOrCreate, key: 1, value: 10}, result{10, false}, map[int]int{1: 10}},
+		{"LoadOrCreate present", map[int]int{1: 10}, operation{kind: opLoadOrCreate, key: 1, value: 11}, result{10, true}, map[int]int{1: 10}},
+		{"Delete missing", map[int]int{2: 20}, operation{kind: opDelete, key: 1}, result{}, map[int]int{2: 20}},
+		{"Delete present", map[int]int{1: 10, 2: 20}, operation{kind: opDelete, key: 1}, result{}, map[int]int{2: 20}},
+		{"CompareAndSwap missing", nil, operation{kind: opCompareAndSwap, key: 1, old: 0, value: 11}, result{}, nil},
+		{"CompareAndSwap equal", map[int]int{1: 10}, operation{kind: opCompareAndSwap, key: 1, old: 10, value: 11}, result{11, true}, map[int]int{1: 11}},
+		{"CompareAndSwap differs", map[int]int{1: 10}, operation{kind: opCompareAndSwap, key: 1, old: 9, value: 11}, result{10, false}, map[int]int{1: 10}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			m := factory()
+			if _, ok := m.(compareAndSwapper[K, V]); !ok && tt.op.kind == opCompareAndSwap {
+				t.Skip("map does not implement CompareAndSwap")
+			}
+			for k, v := range tt.initial {
+				m.Store(c.key(k), c.value(v))
+			}
+			if tt.op.kind == opLoadOrCreate && len(tt.initial) > 0 {
+				_, _ = m.LoadOrCreate(c.key(tt.op.key), func() V {
+					t.Error("generator should not be called for present key")
+					return c.value(tt.op.value)
+				})
+			}
+			if r := run(m, c, tt.op); r != tt.expected {
+				t.Errorf("%s returned (%d, %t), expected (%d, %t)", tt.op, r.value, r.ok, tt.expected.value, tt.expected.ok)
+			}
+			expectState(t, c, m, tt.final)
+		})
+	}
+}
+
+func runRange[K comparable, V comparable](t *testing.T, factory Factory[K, V], c *codec[K, V]) {
+	m := factory()
+	expected := make(map[int]int, 1024)
+	for i := -512; i < 512; i++ {
+		m.Store(c.key(i), c.value(i+513))
+		expected[i] = i + 513
+	}
+	expectState(t, c, m, expected)
+
+	calls := 0
+	m.Range(func(K, V) bool {
+		calls++
+		return calls < 10
+	})
+	if calls != 10 {
+		t.Errorf("Range should stop when cb returns false, but cb is called %d times", calls)
+	}
+
+	// cb is allowed to call map methods
+	m.Range(func(k K, v V) bool {
+		i := c.keyIndex(t, k)
+		if i%2 == 0 {
+			m.Delete(k)
+		} else {
+			m.Store(k, c.value(c.valueIndex(v)+1024))
+		}
+		return true
+	})
+	if _, ok := m.Load(c.key(0)); ok {
+		t.Error("key deleted from Range cb is present")
+	}
+	if v, ok := m.Load(c.key(-1)); !ok || c.valueIndex(v) != 512+1024 {
+		t.Errorf("value stored from Range cb is not loaded: (%v, %t)", v, ok)
+	}
+}
+
+// runSequentialModel executes random operations from single goroutine, and compares results with model.
+func runSequentialModel[K comparable, V comparable](t *testing.T, factory Factory[K, V], c *codec[K, V]) {
+	m := factory()
+	_, cas := m.(compareAndSwapper[K, V])
+	gen := newGenerator(1, sequentialKeys, cas)
+	model := make(map[int]state, sequentialKeys)
+
+	for i := 0; i < sequentialOperations; i++ {
+		op := gen.next()
+		r := run(m, c, op)
+		next, ok := step(model[op.key], op, r)
+		if !ok {
+			t.Fatalf("operation %d %s returned (%d, %t), while model state is %+v", i, op, r.value, r.ok, model[op.key])
+		}
+		model[op.key] = next
+	}
+
+	expected := make(map[int]int, len(model))
+	for k, s := range model {
+		if s.present {
+			expected[k] = s.value
+		}
+	}
+	expectState(t, c, m, expected)
+}
+
+// runLinearizability executes random operations from several goroutines, and verifies that history of each key
+// could be linearized according to sequential model.
+func runLinearizability[K comparable, V comparable](t *testing.T, factory Factory[K, V], c *codec[K, V]) {
+	for round := 0; round < concurrentRounds; round++ {
+		m := factory()
+		_, cas := m.(compareAndSwapper[K, V])
+		gen := newGenerator(int64(round), concurrentKeys, cas)
+		operations := make([][]operation, concurrentGoroutines)
+		for g := range operations {
+			operations[g] = make([]operation, concurrentOperations)
+			for i := range operations[g] {
+				operations[g][i] = gen.next()
+			}
+		}
+
+		var (
+			clock int64
+			wg    sync.WaitGroup
+		)
+		histories := make([][]event, concurrentGoroutines)
+		wg.Add(concurrentGoroutines)
+		for g := range operations {
+			go func(g int) {
+				defer wg.Done()
+				for _, op := range operations[g] {
+					call := atomic.AddInt64(&clock, 1)
+					r := run(m, c, op)
+					histories[g] = append(histories[g], event{
+						operation: op,
+						result:    r,
+						goroutine: g,
+						call:      call,
+						ret:       atomic.AddInt64(&clock, 1),
+					})
+				}
+			}(g)
+		}
+		wg.Wait()
+
+		byKey := make(map[int][]event, concurrentKeys)
+		for _, history := range histories {
+			for _, e := range history {
+				byKey[e.key] = append(byKey[e.key], e)
+			}
+		}
+		for key, history := range byKey {
+			sort.Slice(history, func(i, j int) bool {
+				return history[i].call < history[j].call
+			})
+			if !linearizable(history, state{}) {
+				t.Fatalf("round %d: history of key %d is not linearizable:\n%s", round, key, formatHistory(history))
+			}
+		}
+	}
+}
+
+// codec converts key and value indexes, used by operations and model, to keys and values of the map and back.
+type codec[K comparable, V comparable] struct {
+	gen    Generator[K, V]
+	lock   sync.Mutex
+	keys   map[K]int
+	values map[V]int
+}
+
+func newCodec[K comparable, V comparable](gen Generator[K, V]) *codec[K, V] {
+	return &codec[K, V]{
+		gen:    gen,
+		keys:   make(map[K]int),
+		values: make(map[V]int),
+	}
+}
+
+func (c *codec[K, V]) key(i int) K {
+	k := c.gen.Key(i)
+	c.lock.Lock()
+	c.keys[k] = i
+	c.lock.Unlock()
+	return k
+}
+
+func (c *codec[K, V]) value(i int) V {
+	if i == 0 {
+		var zero V
+		return zero
+	}
+	v := c.gen.Value(i)
+	c.lock.Lock()
+	c.values[v] = i
+	c.lock.Unlock()
+	return v
+}
+
+// keyIndex returns index of the key, which was passed to the map before.
+func (c *codec[K, V]) keyIndex(t *testing.T, k K) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	i, ok := c.keys[k]
+	if !ok {
+		t.Fatalf("map returned key %v, which was never stored", k)
+	}
+	return i
+}
+
+// valueIndex returns index of the value, 0 for zero value, and -1 for value, which was never stored.
+func (c *codec[K, V]) valueIndex(v V) int {
+	var zero V
+	if v == zero {
+		return 0
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if i, ok := c.values[v]; ok {
+		return i
+	}
+	return -1
+}
+
+// expectState verifies, that map contains exactly expected entries, given as indexes.
+func expectState[K comparable, V comparable](t *testing.T, c *codec[K, V], m smap.Map[K, V], expected map[int]int) {
+	t.Helper()
+	actual := make(map[int]int)
+	m.Range(func(k K, v V) bool {
+		actual[c.keyIndex(t, k)] = c.valueIndex(v)
+		return true
+	})
+	if expected == nil {
+		expected = map[int]int{}
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("map contains %v, expected %v (key and value indexes)", actual, expected)
+	}
+}
+
+// generator produces random operations. Values are unique, so results of operations could be distinguished.
+type generator struct {
+	rnd    *rand.Rand
+	keys   int
+	cas    bool
+	values []int
+}
+
+func newGenerator(seed int64, keys int, cas bool) *generator {
+	return &generator{
+		rnd:  rand.New(rand.NewSource(seed)),
+		keys: keys,
+		cas:  cas,
+	}
+}
+
+func (g *generator) next() operation {
+	kinds := opDelete + 1
+	if g.cas {
+		kinds = opCompareAndSwap + 1
+	}
+	op := operation{
+		kind:  opKind(g.rnd.Intn(int(kinds))),
+		key:   g.rnd.Intn(g.keys),
+		value: len(g.values) + 1,
+	}
+	if op.kind == opCompareAndSwap && len(g.values) > 0 && g.rnd.Intn(4) > 0 {
+		// one of recently generated values, so swap sometimes succeeds
+		op.old = g.values[len(g.values)-1-g.rnd.Intn(minInt(len(g.values), 2*g.keys))]
+	}
+	g.values = append(g.values, op.value)
+	return op
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,136 @@
+package smaptest_test
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/lispad/go-generics-tools/smap"
+	"github.com/lispad/go-generics-tools/smap/shard"
+	"github.com/lispad/go-generics-tools/smap/smaptest"
+)
+
+func TestGeneric(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewGeneric[int, int](8, 16, shard.Must(shard.JumpHash[int](8)))
+	}, smaptest.Ints())
+}
+
+func TestGenericComparable(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewGenericComparable[int, int](8, 16, shard.Must(shard.Fibonacci[int](8)))
+	}, smaptest.Ints())
+}
+
+func TestInteger(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewInteger[int, int](8, 16)
+	}, smaptest.Ints())
+}
+
+func TestIntegerWithDetector(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewIntegerComparable[int, int](8, 16, smap.WithIntegerDetector(shard.Must(shard.Mask[uint64](8))))
+	}, smaptest.Ints())
+}
+
+func TestGenericAutoCompaction(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewInteger[int, int](2, 16, smap.WithAutoCompaction(0.1))
+	}, smaptest.Ints())
+}
+
+func TestGenericBloomFilter(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		// tiny filter, so false positives are frequent too
+		return smap.NewInteger[int, int](4, 16, smap.WithBloomFilter(func(key int) uint64 {
+			return uint64(key)
+		}, 4, 0.5))
+	}, smaptest.Ints())
+}
+
+func TestSwiss(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewSwiss[int, int](4, 0, func(key int) uint64 {
+			return uint64(key) * 11400714819323198485
+		})
+	}, smaptest.Ints())
+}
+
+func TestSwissLocks(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewSwiss[int, int](4, 0, func(key int) uint64 {
+			return uint64(key) * 11400714819323198485
+		}, smap.WithLock(smap.LockBRAVO), smap.WithStats())
+	}, smaptest.Ints())
+}
+
+func TestGenericLocks(t *testing.T) {
+	for name, kind := range map[string]smap.LockKind{
+		"Mutex": smap.LockMutex,
+		"Spin":  smap.LockSpin,
+		"BRAVO": smap.LockBRAVO,
+	} {
+		kind := kind
+		t.Run(name, func(t *testing.T) {
+			smaptest.RunConformance(t, func() smap.Map[int, int] {
+				return smap.NewInteger[int, int](4, 16, smap.WithLock(kind))
+			}, smaptest.Ints())
+		})
+	}
+}
+
+func TestGenericStats(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewInteger[int, int](4, 16, smap.WithStats(), smap.WithLock(smap.LockBRAVO))
+	}, smaptest.Ints())
+}
+
+func TestSyncMap(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.FromSyncMap[int, int](&sync.Map{})
+	}, smaptest.Ints())
+}
+
+func TestLocked(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewLocked[int, int](16)
+	}, smaptest.Ints())
+}
+
+func TestAutoTuned(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewAutoTuned[int, int](func(key int) uint64 {
+			return uint64(key) * 11400714819323198485
+		}, smap.AutoTuneConfig{InitialShards: 4})
+	}, smaptest.Ints())
+}
+
+func TestVersioned(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewVersioned[int, int](8, 16, shard.Must(shard.Modulo[int](8)))
+	}, smaptest.Ints())
+}
+
+func TestGenericEq(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[int, int] {
+		return smap.NewGenericEq[int, int](8, 16, shard.Must(shard.Modulo[int](8)), smap.DeepEqual[int])
+	}, smaptest.Ints())
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestGenericStringKeys(t *testing.T) {
+	smaptest.RunConformance(t, func() smap.Map[string, point] {
+		return smap.NewGenericComparable[string, point](8, 16, shard.Must(shard.XXHash(8)))
+	}, smaptest.Generator[string, point]{
+		Key: func(i int) string {
+			return "key-" + strconv.Itoa(i)
+		},
+		Value: func(i int) point {
+			return point{X: i, Y: -i}
+		},
+	})
+}
@@ -0,0 +1,112 @@
+package smap
+
+import "sync/atomic"
+
+// WithStats enables counting of map operations, shard lock acquisitions and contended lock attempts,
+// reported by Generic.Stats. Each lock is tried without blocking first, so counting adds atomic increments
+// and a TryLock call to each operation.
+func WithStats() Option {
+	return func(o *options) {
+		o.stats = true
+	}
+}
+
+// Stats is snapshot of map statistics. Counters are zero, unless map was created with WithStats option.
+type Stats struct {
+	// ShardLens is count of entries in each shard.
+	ShardLens []int
+	// Counted is true, if map was created with WithStats option, so counters are collected.
+	Counted bool
+
+	// Loads, Stores and Deletes are counts of map operations: Load and ReadValue are loads, LoadAndDelete and Delete
+	// are deletes. LoadOrCreate, WithValue and conditional operations, like CompareAndSwap, are counted by their
+	// outcome: as load, if map is not changed. Range, parallel and shard-level methods are not counted
+	// as operations, though they take locks.
+	Loads   int64
+	Stores  int64
+	Deletes int64
+	// Reads and Writes are counts of shard read and write lock acquisitions. Most operations take one lock,
+	// but lookups, rejected by Bloom filter, take none, and Range takes read lock for each key.
+	Reads  int64
+	Writes int64
+	// ContendedReads and ContendedWrites are counts of lock attempts, which found shard locked by other goroutine,
+	// including failed TryLock calls.
+	ContendedReads  int64
+	ContendedWrites int64
+}
+
+// Len returns count of entries in all shards.
+func (s Stats) Len() int {
+	count := 0
+	for _, l := range s.ShardLens {
+		count += l
+	}
+	return count
+}
+
+// Stats returns map statistics. Shard lengths are read under shard read locks, which are not counted in Stats,
+// so collecting statistics does not affect lock counters.
+func (sm Generic[K, V]) Stats() Stats {
+	var s Stats
+	s.ShardLens = make([]int, len(sm.shards))
+	for i := range sm.locks {
+		s.addLockStats(&sm.locks[i])
+		sm.locks[i].uncountedRLock()
+		s.ShardLens[i] = len(sm.shards[i])
+		sm.locks[i].RUnlock()
+	}
+	return s
+}
+
+// addLockStats adds counters of the lock, if they are enabled.
+func (s *Stats) addLockStats(l *shardLock) {
+	if e := l.ext; e != nil && e.stats != nil {
+		s.Counted = true
+		s.Loads += atomic.LoadInt64(&e.stats.ops[statLoad])
+		s.Stores += atomic.LoadInt64(&e.stats.ops[statStore])
+		s.Deletes += atomic.LoadInt64(&e.stats.ops[statDelete])
+		s.Reads += atomic.LoadInt64(&e.stats.reads)
+		s.Writes += atomic.LoadInt64(&e.stats.writes)
+		s.ContendedReads += atomic.LoadInt64(&e.stats.contendedReads)
+		s.ContendedWrites += atomic.LoadInt64(&e.stats.contendedWrites)
+	}
+}
+
+// Map operations, counted in lockStats.ops.
+const (
+	statLoad = iota
+	statStore
+	statDelete
+)
+
+// lockStats counts acquisitions of shard lock and operations on the shard.
+type lockStats struct {
+	reads           int64
+	writes          int64
+	contendedReads  int64
+	contendedWrites int64
+	ops             [3]int64
+	_               [8]byte // counters of adjacent shards are on separate cache lines
+}
+
+func enableLockStats(locks []shardLock) {
+	for i := range locks {
+		locks[i].extension(i).stats = &lockStats{}
+	}
+}
+
+// count counts successful or contended lock attempt.
+func (s *lockStats) count(acquired, contended *int64, ok bool) {
+	if ok {
+		atomic.AddInt64(acquired, 1)
+	} else {
+		atomic.AddInt64(contended, 1)
+	}
+}
+
+// countOp counts map operation on the shard of the lock, if WithStats option is set.
+func (l *shardLock) countOp(op int) {
+	if e := l.ext; e != nil && e.stats != nil {
+		atomic.AddInt64(&e.stats.ops[op], 1)
+	}
+}
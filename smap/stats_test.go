@@ -0,0 +1,78 @@
+package smap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneric_Stats(t *testing.T) {
+	m := NewInteger[int, int](4, 0, WithStats())
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	m.Load(1)
+	m.Delete(2)
+
+	s := m.Stats()
+	assert.True(t, s.Counted)
+	assert.Equal(t, 99, s.Len())
+	assert.Len(t, s.ShardLens, 4)
+	assert.Equal(t, int64(101), s.Writes)
+	assert.Equal(t, int64(1), s.Reads)
+	assert.Zero(t, s.ContendedWrites)
+	assert.Equal(t, int64(1), s.Loads)
+	assert.Equal(t, int64(100), s.Stores)
+	assert.Equal(t, int64(1), s.Deletes)
+
+	// Stats does not count its own locks
+	assert.Equal(t, s, m.Stats())
+
+	m.LockShard(0)
+	assert.False(t, m.TryRLockShard(0))
+	assert.False(t, m.TryLockShard(0))
+	m.UnlockShard(0)
+	s = m.Stats()
+	assert.Equal(t, int64(1), s.ContendedReads)
+	assert.Equal(t, int64(1), s.ContendedWrites)
+	assert.Equal(t, int64(102), s.Writes)
+}
+
+func TestGeneric_StatsOperations(t *testing.T) {
+	m := NewIntegerComparable[int, int](4, 0, WithStats())
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	s := m.Stats()
+
+	// Range takes read lock for each key, but it doesn't load
+	m.Range(func(int, int) bool {
+		return true
+	})
+	m.LoadOrCreate(1, func() int { return 0 })   // load
+	m.LoadOrCreate(100, func() int { return 0 }) // store
+	m.CompareAndSwap(1, 1, 2)                    // store
+	m.CompareAndSwap(1, 1, 3)                    // load
+	m.WithValue(2, func(v *int, exists bool) bool {
+		return false
+	}) // delete
+	m.ReadValue(3, func(*int) {}) // load
+
+	next := m.Stats()
+	assert.Greater(t, next.Reads-s.Reads, int64(100), "Range takes read locks")
+	assert.Equal(t, s.Loads+3, next.Loads)
+	assert.Equal(t, s.Stores+2, next.Stores)
+	assert.Equal(t, s.Deletes+1, next.Deletes)
+}
+
+func TestGeneric_StatsDisabled(t *testing.T) {
+	m := NewInteger[int, int](4, 0)
+	m.Store(1, 1)
+	m.Load(1)
+	s := m.Stats()
+	assert.False(t, s.Counted)
+	assert.Equal(t, 1, s.Len())
+	assert.Zero(t, s.Reads)
+	assert.Zero(t, s.Writes)
+	assert.Zero(t, s.Loads)
+}
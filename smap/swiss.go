@@ -0,0 +1,344 @@
+package smap
+
+import (
+	"context"
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	swissGroupSize = 8
+	swissEmpty     = 0x80
+	swissDeleted   = 0xfe
+
+	swissLSB = 0x0101010101010101
+	swissMSB = 0x8080808080808080
+)
+
+// Swiss stores data in N shards, with lock for each, like Generic does, but shards are Swiss-table-style
+// open-addressing hash tables instead of Go maps. Key hash is computed once, and is used both for shard selection
+// and for bucket probing. Entries are stored inline, groups of 8 slots are probed with SWAR matching of control bytes.
+type Swiss[K comparable, V any] struct {
+	tables []swissTable[K, V]
+	locks  []shardLock
+	hash   func(key K) uint64
+}
+
+var _ Map[int, int] = Swiss[int, int]{}
+
+// NewSwiss creates sharded map with open-addressing shard tables. hash should be well-distributed:
+// its high bits select shard, and low bits select group and control byte.
+// WithLock and WithStats options apply like for Generic. WithAutoCompaction and WithBloomFilter are ignored:
+// tables reuse deleted slots and drop them on rehash, and hash probing rejects missing keys by control bytes.
+func NewSwiss[K comparable, V any](shardsCount, defaultSize int, hash func(key K) uint64, opts ...Option) Swiss[K, V] {
+	o := applyOptions(opts)
+	sm := Swiss[K, V]{
+		tables: make([]swissTable[K, V], shardsCount),
+		locks:  newShardLocks(shardsCount, o.lockKind),
+		hash:   hash,
+	}
+	for i := range sm.tables {
+		sm.tables[i].hash = hash
+		sm.tables[i].init(defaultSize)
+	}
+	if o.stats {
+		enableLockStats(sm.locks)
+	}
+	return sm
+}
+
+// Load returns the value stored in the map for a key.
+// The ok result indicates whether value was found in the map.
+func (sm Swiss[K, V]) Load(key K) (V, bool) {
+	hash := sm.hash(key)
+	shardID := sm.shardID(hash)
+	sm.locks[shardID].countOp(statLoad)
+	return sm.load(key, hash, shardID)
+}
+
+// load returns the value for a key, without counting the operation in stats.
+func (sm Swiss[K, V]) load(key K, hash uint64, shardID int) (V, bool) {
+	sm.locks[shardID].RLock()
+	t := &sm.tables[shardID]
+	if i := t.find(key, hash); i >= 0 {
+		value := t.slots[i].value
+		sm.locks[shardID].RUnlock()
+		return value, true
+	}
+	sm.locks[shardID].RUnlock()
+	var zero V
+	return zero, false
+}
+
+// Store sets the value for a key.
+func (sm Swiss[K, V]) Store(key K, value V) {
+	hash := sm.hash(key)
+	shardID := sm.shardID(hash)
+	sm.locks[shardID].countOp(statStore)
+	sm.locks[shardID].Lock()
+	t := &sm.tables[shardID]
+	if i := t.find(key, hash); i >= 0 {
+		t.slots[i].value = value
+	} else {
+		t.insert(key, value, hash)
+	}
+	sm.locks[shardID].Unlock()
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (sm Swiss[K, V]) LoadAndDelete(key K) (V, bool) {
+	hash := sm.hash(key)
+	shardID := sm.shardID(hash)
+	sm.locks[shardID].countOp(statDelete)
+	sm.locks[shardID].Lock()
+	defer sm.locks[shardID].Unlock()
+	t := &sm.tables[shardID]
+	i := t.find(key, hash)
+	if i < 0 {
+		var zero V
+		return zero, false
+	}
+	value := t.slots[i].value
+	t.remove(i)
+	return value, true
+}
+
+// LoadOrCreate returns the existing value for the key if present.
+// Otherwise, it calls generator func, stores and returns the generator's result.
+// Generator will not be called if key present.
+// The loaded result is true if the value was loaded, false if stored.
+func (sm Swiss[K, V]) LoadOrCreate(key K, generator func() V) (V, bool) {
+	hash := sm.hash(key)
+	shardID := sm.shardID(hash)
+	t := &sm.tables[shardID]
+	if value, ok := sm.load(key, hash, shardID); ok {
+		sm.locks[shardID].countOp(statLoad)
+		return value, true
+	}
+
+	sm.locks[shardID].Lock()
+	defer sm.locks[shardID].Unlock()
+	if i := t.find(key, hash); i >= 0 {
+		sm.locks[shardID].countOp(statLoad)
+		return t.slots[i].value, true
+	}
+	sm.locks[shardID].countOp(statStore)
+	value := generator()
+	t.insert(key, value, hash)
+	return value, false
+}
+
+// Delete deletes the value for a key.
+func (sm Swiss[K, V]) Delete(key K) {
+	hash := sm.hash(key)
+	shardID := sm.shardID(hash)
+	sm.locks[shardID].countOp(statDelete)
+	sm.locks[shardID].Lock()
+	t := &sm.tables[shardID]
+	if i := t.find(key, hash); i >= 0 {
+		t.remove(i)
+	}
+	sm.locks[shardID].Unlock()
+}
+
+// Range calls cb sequentially for each key and value present in the map.
+// If cb returns false, range stops the iteration. Consistency guarantees are the same as for Generic.Range.
+func (sm Swiss[K, V]) Range(cb func(K, V) bool) {
+	for id := range sm.tables {
+		for _, key := range sm.shardKeys(id) {
+			if value, ok := sm.load(key, sm.hash(key), id); ok && !cb(key, value) {
+				return
+			}
+		}
+	}
+}
+
+// RangeParallel calls cb for each key and value present in the map, processing shards concurrently
+// from workers goroutines. See Generic.RangeParallel for details.
+func (sm Swiss[K, V]) RangeParallel(ctx context.Context, workers int, cb func(K, V) bool) error {
+	return parallelShards(ctx, sm.ShardsCount(), workers, func(id int, stopped func() bool) bool {
+		for _, key := range sm.shardKeys(id) {
+			if stopped() {
+				return true
+			}
+			if value, ok := sm.load(key, sm.hash(key), id); ok && !cb(key, value) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Stats returns map statistics, see Generic.Stats.
+func (sm Swiss[K, V]) Stats() Stats {
+	var s Stats
+	s.ShardLens = make([]int, len(sm.tables))
+	for i := range sm.locks {
+		s.addLockStats(&sm.locks[i])
+		sm.locks[i].uncountedRLock()
+		s.ShardLens[i] = sm.tables[i].count
+		sm.locks[i].RUnlock()
+	}
+	return s
+}
+
+// Len returns count of entries in the map.
+func (sm Swiss[K, V]) Len() int {
+	count := 0
+	for id := range sm.tables {
+		sm.locks[id].RLock()
+		count += sm.tables[id].count
+		sm.locks[id].RUnlock()
+	}
+	return count
+}
+
+// ShardsCount returns count of shards.
+func (sm Swiss[K, V]) ShardsCount() int {
+	return len(sm.tables)
+}
+
+// shardKeys returns keys of the shard, collected under shard read lock.
+func (sm Swiss[K, V]) shardKeys(id int) []K {
+	sm.locks[id].RLock()
+	defer sm.locks[id].RUnlock()
+	t := &sm.tables[id]
+	keys := make([]K, 0, t.count)
+	for i, c := range t.ctrl {
+		if c&swissEmpty == 0 {
+			keys = append(keys, t.slots[i].key)
+		}
+	}
+	return keys
+}
+
+func (sm Swiss[K, V]) shardID(hash uint64) int {
+	hi, _ := bits.Mul64(hash, uint64(len(sm.tables)))
+	return int(hi)
+}
+
+type swissSlot[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// swissTable is open-addressing hash table. Slots are divided to groups of 8, with control byte for each slot:
+// swissEmpty, swissDeleted (tombstone), or 7 low bits of hash for full slot.
+type swissTable[K comparable, V any] struct {
+	ctrl  []byte
+	slots []swissSlot[K, V]
+	mask  uint64 // groups count - 1
+	count int
+	// growthLeft is count of empty slots, which could be filled before rehash.
+	growthLeft int
+	// hash is used to rehash entries, hashes are not stored in slots.
+	hash func(key K) uint64
+}
+
+func (t *swissTable[K, V]) init(size int) {
+	groups := 1
+	// max load factor is 7/8
+	for groups*swissGroupSize*7/8 < size {
+		groups *= 2
+	}
+	t.ctrl = make([]byte, groups*swissGroupSize)
+	for i := range t.ctrl {
+		t.ctrl[i] = swissEmpty
+	}
+	t.slots = make([]swissSlot[K, V], groups*swissGroupSize)
+	t.mask = uint64(groups - 1)
+	t.count = 0
+	t.growthLeft = groups * swissGroupSize * 7 / 8
+}
+
+// find returns slot index of the key, or -1 if key is missing.
+func (t *swissTable[K, V]) find(key K, hash uint64) int {
+	h2 := byte(hash & 0x7f)
+	group := (hash >> 7) & t.mask
+	for step := uint64(1); ; step++ {
+		ctrl := t.group(group)
+		for m := swissMatch(ctrl, h2); m != 0; m &= m - 1 {
+			i := int(group)*swissGroupSize + bits.TrailingZeros64(m)/8
+			if t.slots[i].key == key {
+				return i
+			}
+		}
+		if swissMatchEmpty(ctrl) != 0 {
+			return -1
+		}
+		group = (group + step) & t.mask
+	}
+}
+
+// insert adds missing key to the table.
+func (t *swissTable[K, V]) insert(key K, value V, hash uint64) {
+	if t.growthLeft == 0 {
+		t.rehash()
+	}
+	i := t.freeSlot(hash)
+	if t.ctrl[i] == swissEmpty {
+		t.growthLeft--
+	}
+	t.ctrl[i] = byte(hash & 0x7f)
+	t.slots[i] = swissSlot[K, V]{key: key, value: value}
+	t.count++
+}
+
+// freeSlot returns index of the first empty or deleted slot in the key probe sequence.
+func (t *swissTable[K, V]) freeSlot(hash uint64) int {
+	group := (hash >> 7) & t.mask
+	for step := uint64(1); ; step++ {
+		if m := swissMatchEmptyOrDeleted(t.group(group)); m != 0 {
+			return int(group)*swissGroupSize + bits.TrailingZeros64(m)/8
+		}
+		group = (group + step) & t.mask
+	}
+}
+
+// remove deletes entry in the slot. Slot is marked empty if its group has empty slots, since probing
+// never continued past such group, otherwise tombstone is left.
+func (t *swissTable[K, V]) remove(i int) {
+	t.slots[i] = swissSlot[K, V]{}
+	t.count--
+	if swissMatchEmpty(t.group(uint64(i/swissGroupSize))) != 0 {
+		t.ctrl[i] = swissEmpty
+		t.growthLeft++
+	} else {
+		t.ctrl[i] = swissDeleted
+	}
+}
+
+// rehash rebuilds table, dropping tombstones. Table is grown twice, unless at least half of it is tombstones.
+func (t *swissTable[K, V]) rehash() {
+	ctrl, slots := t.ctrl, t.slots
+	size := len(slots) * 7 / 8
+	if t.count >= size/2 {
+		size *= 2
+	}
+	t.init(size)
+	for i, c := range ctrl {
+		if c&swissEmpty == 0 {
+			t.insert(slots[i].key, slots[i].value, t.hash(slots[i].key))
+		}
+	}
+}
+
+func (t *swissTable[K, V]) group(group uint64) uint64 {
+	return binary.LittleEndian.Uint64(t.ctrl[group*swissGroupSize:])
+}
+
+// swissMatch returns bitmask with high bit set in each byte of ctrl, which equals h2.
+// It could report false positive for byte next to the matching one, so keys should be compared anyway.
+func swissMatch(ctrl uint64, h2 byte) uint64 {
+	x := ctrl ^ (swissLSB * uint64(h2))
+	return (x - swissLSB) &^ x & swissMSB
+}
+
+func swissMatchEmpty(ctrl uint64) uint64 {
+	return ctrl &^ (ctrl << 6) & swissMSB
+}
+
+func swissMatchEmptyOrDeleted(ctrl uint64) uint64 {
+	return ctrl & swissMSB
+}
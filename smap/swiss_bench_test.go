@@ -0,0 +1,85 @@
+package smap_test
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/lispad/go-generics-tools/smap"
+)
+
+const swissBenchmarkKeys = 1 << 20
+
+// BenchmarkSwiss_MemoryPerEntry reports heap bytes per entry for Swiss and Generic maps of the same size.
+func BenchmarkSwiss_MemoryPerEntry(b *testing.B) {
+	b.Run("Generic", func(b *testing.B) {
+		benchmarkMemoryPerEntry(b, func() interface{} {
+			sm := smap.NewInteger[int, int](64, 0)
+			for i := 0; i < swissBenchmarkKeys; i++ {
+				sm.Store(i, i)
+			}
+			return sm
+		})
+	})
+	b.Run("Swiss", func(b *testing.B) {
+		benchmarkMemoryPerEntry(b, func() interface{} {
+			sm := smap.NewSwiss[int, int](64, 0, fibonacciHash)
+			for i := 0; i < swissBenchmarkKeys; i++ {
+				sm.Store(i, i)
+			}
+			return sm
+		})
+	})
+}
+
+func benchmarkMemoryPerEntry(b *testing.B, build func() interface{}) {
+	var bytesPerEntry float64
+	for i := 0; i < b.N; i++ {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		sm := build()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(sm)
+		bytesPerEntry = float64(after.HeapAlloc-before.HeapAlloc) / swissBenchmarkKeys
+	}
+	b.ReportMetric(bytesPerEntry, "B/entry")
+}
+
+// BenchmarkSwiss_ConcurrentGet compares lookups of present and missing keys in Swiss and Generic maps.
+func BenchmarkSwiss_ConcurrentGet(b *testing.B) {
+	generic := smap.NewInteger[int, int](64, swissBenchmarkKeys/64)
+	swiss := smap.NewSwiss[int, int](64, swissBenchmarkKeys/64, fibonacciHash)
+	for i := 0; i < swissBenchmarkKeys; i++ {
+		generic.Store(i*2, i)
+		swiss.Store(i*2, i)
+	}
+
+	for _, missing := range []bool{false, true} {
+		for _, m := range []struct {
+			name string
+			load func(int) (int, bool)
+		}{
+			{"Generic", generic.Load},
+			{"Swiss", swiss.Load},
+		} {
+			m := m
+			b.Run(fmt.Sprintf("%s/missing=%t", m.name, missing), func(b *testing.B) {
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					i := rand.Intn(swissBenchmarkKeys) * 2
+					if missing {
+						i++
+					}
+					for pb.Next() {
+						m.load(i)
+						i = (i + 2) % (swissBenchmarkKeys * 2)
+					}
+				})
+			})
+		}
+	}
+}
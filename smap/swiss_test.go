@@ -0,0 +1,144 @@
+package smap
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSwiss_LoadStoreDelete(t *testing.T) {
+	m := NewSwiss[int, int](4, 0, intHash)
+	for i := 0; i < 10000; i++ {
+		m.Store(i, i)
+	}
+	assert.Equal(t, 10000, m.Len())
+	for i := 0; i < 10000; i++ {
+		v, ok := m.Load(i)
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+	_, ok := m.Load(10000)
+	assert.False(t, ok)
+
+	for i := 0; i < 10000; i += 2 {
+		m.Delete(i)
+	}
+	m.Store(1, -1)
+	assert.Equal(t, 5000, m.Len())
+	for i := 0; i < 10000; i++ {
+		v, ok := m.Load(i)
+		assert.Equal(t, i%2 == 1, ok)
+		if ok && i != 1 {
+			assert.Equal(t, i, v)
+		}
+	}
+	v, _ := m.Load(1)
+	assert.Equal(t, -1, v)
+}
+
+func TestSwiss_CollidingHashes(t *testing.T) {
+	// all keys have the same hash, so each lookup probes all groups
+	m := NewSwiss[int, int](1, 0, func(int) uint64 {
+		return 42
+	})
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	for i := 0; i < 100; i += 3 {
+		v, ok := m.LoadAndDelete(i)
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+	for i := 0; i < 100; i++ {
+		_, ok := m.Load(i)
+		assert.Equal(t, i%3 != 0, ok)
+	}
+}
+
+func TestSwiss_RandomOperations(t *testing.T) {
+	m := NewSwiss[int, int](2, 16, intHash)
+	model := make(map[int]int)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 200000; i++ {
+		key := rnd.Intn(1000)
+		switch rnd.Intn(3) {
+		case 0:
+			m.Store(key, i)
+			model[key] = i
+		case 1:
+			m.Delete(key)
+			delete(model, key)
+		default:
+			v, ok := m.Load(key)
+			expected, exists := model[key]
+			if !assert.Equal(t, exists, ok) || !assert.Equal(t, expected, v) {
+				return
+			}
+		}
+	}
+	assert.Equal(t, len(model), m.Len())
+
+	// tombstones are dropped on rehash, so table does not grow with repeated insert/delete cycles
+	for _, table := range m.tables {
+		assert.LessOrEqual(t, len(table.slots), 1024)
+	}
+}
+
+func TestSwiss_Options(t *testing.T) {
+	m := NewSwiss[int, int](4, 0, intHash, WithLock(LockMutex), WithStats())
+	assert.IsType(t, &mutexLock{}, m.locks[0].ext.custom)
+	for i := 0; i < 100; i++ {
+		m.Store(i, i)
+	}
+	for i := 0; i < 50; i++ {
+		m.Load(i)
+	}
+
+	s := m.Stats()
+	assert.Equal(t, 100, s.Len())
+	assert.Len(t, s.ShardLens, 4)
+	assert.Equal(t, int64(100), s.Writes)
+	assert.Equal(t, int64(50), s.Reads)
+	assert.Equal(t, int64(100), s.Stores)
+	assert.Equal(t, int64(50), s.Loads)
+	assert.Equal(t, s, m.Stats(), "Stats should not count its own locks")
+
+	m.Range(func(int, int) bool {
+		return true
+	})
+	assert.Equal(t, int64(50), m.Stats().Loads, "Range should not count loads")
+
+	assert.Equal(t, Stats{ShardLens: []int{0, 0}}, NewSwiss[int, int](2, 0, intHash).Stats())
+}
+
+func TestSwiss_RangeParallel(t *testing.T) {
+	m := NewSwiss[int, int](16, 0, intHash)
+	expected := make(map[int]int, 1024)
+	for i := 0; i < 1024; i++ {
+		m.Store(i, i*i)
+		expected[i] = i * i
+	}
+
+	var mu sync.Mutex
+	result := make(map[int]int, 1024)
+	err := m.RangeParallel(context.Background(), 4, func(k int, v int) bool {
+		mu.Lock()
+		result[k] = v
+		mu.Unlock()
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+}
+
+func TestSwissMatch(t *testing.T) {
+	ctrl := uint64(0x80fe0512_05807f00)
+	// bytes 3 and 5 match, false positives are allowed only next to them
+	assert.Equal(t, uint64(0x00008000_80000000), swissMatch(ctrl, 0x05)&^0x00800080_00000000)
+	assert.Equal(t, uint64(0), swissMatch(ctrl, 0x06))
+	assert.Equal(t, uint64(0x80000000_00800000), swissMatchEmpty(ctrl))
+	assert.Equal(t, uint64(0x80800000_00800000), swissMatchEmptyOrDeleted(ctrl))
+}
@@ -0,0 +1,44 @@
+package smap
+
+// WithValue calls fn with pointer to the value for a key, holding shard write lock,
+// so value could be read and mutated without race window between Load and Store.
+// If key is missing, fn gets pointer to zero value, and exists is false.
+// If fn returns true, value is stored, otherwise key is deleted.
+// fn should not call methods of sm for keys from the same shard, and should not retain the pointer.
+// Go maps do not allow pointers to values, so value is copied to local variable and back under the same lock:
+// each call copies V twice, which matters for large values.
+func (sm Generic[K, V]) WithValue(key K, fn func(v *V, exists bool) (keep bool)) {
+	shardID := sm.shardDetector(key)
+	sm.locks[shardID].Lock()
+	defer sm.locks[shardID].Unlock()
+	value, exists := sm.shards[shardID][key]
+	if fn(&value, exists) {
+		sm.locks[shardID].countOp(statStore)
+		sm.storing(shardID, key)
+		sm.shards[shardID][key] = value
+	} else if exists {
+		sm.locks[shardID].countOp(statDelete)
+		delete(sm.shards[shardID], key)
+		sm.removed(shardID, key)
+		sm.deleted(shardID, 1)
+	}
+}
+
+// ReadValue calls fn with pointer to the value for a key, holding shard read lock, so several fields could be read
+// consistently. Like WithValue, it copies the value from the map to local variable, so it's not cheaper than Load
+// for large values: store pointers, if copying is too expensive. fn should not retain the pointer.
+// fn is not called if key is missing. The ok result indicates whether value was found in the map.
+func (sm Generic[K, V]) ReadValue(key K, fn func(v *V)) bool {
+	shardID := sm.shardDetector(key)
+	sm.locks[shardID].countOp(statLoad)
+	if !sm.mayContain(shardID, key) {
+		return false
+	}
+	sm.locks[shardID].RLock()
+	defer sm.locks[shardID].RUnlock()
+	value, ok := sm.shards[shardID][key]
+	if ok {
+		fn(&value)
+	}
+	return ok
+}
@@ -0,0 +1,82 @@
+package smap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counters struct {
+	hits   int
+	misses int
+	tags   []string
+}
+
+func TestGeneric_WithValue(t *testing.T) {
+	m := NewInteger[int, counters](8, 128)
+	m.WithValue(1, func(v *counters, exists bool) bool {
+		assert.False(t, exists)
+		assert.Equal(t, counters{}, *v)
+		v.hits++
+		v.tags = append(v.tags, "first")
+		return true
+	})
+	m.WithValue(1, func(v *counters, exists bool) bool {
+		assert.True(t, exists)
+		v.misses++
+		return true
+	})
+	val, ok := m.Load(1)
+	assert.True(t, ok)
+	assert.Equal(t, counters{hits: 1, misses: 1, tags: []string{"first"}}, val)
+
+	m.WithValue(2, func(v *counters, exists bool) bool {
+		v.hits++
+		return false // missing key is not stored
+	})
+	_, ok = m.Load(2)
+	assert.False(t, ok)
+
+	m.WithValue(1, func(v *counters, exists bool) bool {
+		return false // existing key is deleted
+	})
+	_, ok = m.Load(1)
+	assert.False(t, ok)
+}
+
+func TestGeneric_WithValueConcurrent(t *testing.T) {
+	m := NewInteger[int, counters](8, 128)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				m.WithValue(i%4, func(v *counters, exists bool) bool {
+					v.hits++
+					return true
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 4; i++ {
+		val, _ := m.Load(i)
+		assert.Equal(t, 2000, val.hits) // no increments are lost
+	}
+}
+
+func TestGeneric_ReadValue(t *testing.T) {
+	m := NewInteger[int, counters](8, 128)
+	m.Store(1, counters{hits: 10})
+	ok := m.ReadValue(1, func(v *counters) {
+		assert.Equal(t, 10, v.hits)
+	})
+	assert.True(t, ok)
+
+	ok = m.ReadValue(2, func(v *counters) {
+		panic("fn should not be called for missing key")
+	})
+	assert.False(t, ok)
+}
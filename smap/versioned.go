@@ -0,0 +1,156 @@
+package smap
+
+// Versioned stores data in N shards, with rw mutex for each, and version for each entry.
+// Each write sets new version of entry: versions are increasing for each shard, so version of a key is never reused,
+// even if key was deleted and stored again. Versions could be used for optimistic concurrency control, e.g. as ETags.
+// Version 0 means missing key. Values are not required to be comparable.
+type Versioned[K comparable, V any] struct {
+	m Generic[K, versionedValue[V]]
+	// versions stores the last version of each shard, guarded by shard write lock.
+	versions []uint64
+}
+
+type versionedValue[V any] struct {
+	value   V
+	version uint64
+}
+
+var _ Map[int, int] = Versioned[int, int]{}
+
+// NewVersioned creates RWLocked Sharded map with versioned entries.
+// shardDetector should be idempotent function.
+func NewVersioned[K comparable, V any](shardsCount, defaultSize int, shardDetector func(key K) int, opts ...Option) Versioned[K, V] {
+	return Versioned[K, V]{
+		m:        NewGeneric[K, versionedValue[V]](shardsCount, defaultSize, shardDetector, opts...),
+		versions: make([]uint64, shardsCount),
+	}
+}
+
+// Load returns the value stored in the map for a key.
+// The ok result indicates whether value was found in the map.
+func (vm Versioned[K, V]) Load(key K) (V, bool) {
+	value, _, ok := vm.LoadVersioned(key)
+	return value, ok
+}
+
+// LoadVersioned returns the value stored in the map for a key, and its version.
+// The ok result indicates whether value was found in the map, version is 0 if it wasn't.
+func (vm Versioned[K, V]) LoadVersioned(key K) (V, uint64, bool) {
+	entry, ok := vm.m.Load(key)
+	return entry.value, entry.version, ok
+}
+
+// Store sets the value for a key.
+func (vm Versioned[K, V]) Store(key K, value V) {
+	vm.StoreVersioned(key, value)
+}
+
+// StoreVersioned sets the value for a key, and returns its new version.
+func (vm Versioned[K, V]) StoreVersioned(key K, value V) uint64 {
+	shardID := vm.m.shardDetector(key)
+	vm.m.locks[shardID].Lock()
+	version := vm.store(shardID, key, value)
+	vm.m.locks[shardID].Unlock()
+	return version
+}
+
+// StoreIfVersion sets the value for a key, if and only if current version of the key equals expectedVersion.
+// Zero expectedVersion means that key should be missing.
+// Returns new version if value is stored, and current version otherwise.
+// The ok result indicates whether value was stored.
+func (vm Versioned[K, V]) StoreIfVersion(key K, value V, expectedVersion uint64) (uint64, bool) {
+	shardID := vm.m.shardDetector(key)
+	vm.m.locks[shardID].Lock()
+	defer vm.m.locks[shardID].Unlock()
+	if current := vm.m.shards[shardID][key].version; current != expectedVersion {
+		vm.m.locks[shardID].countOp(statLoad)
+		return current, false
+	}
+	return vm.store(shardID, key, value), true
+}
+
+// LoadAndDelete deletes the value for a key, returning the previous value if any.
+// The loaded result reports whether the key was present.
+func (vm Versioned[K, V]) LoadAndDelete(key K) (V, bool) {
+	entry, ok := vm.m.LoadAndDelete(key)
+	return entry.value, ok
+}
+
+// LoadOrCreate returns the existing value for the key if present.
+// Otherwise, it calls generator func, stores and returns the generator's result.
+// Generator will not be called if key present.
+// The loaded result is true if the value was loaded, false if stored.
+func (vm Versioned[K, V]) LoadOrCreate(key K, generator func() V) (V, bool) {
+	shardID := vm.m.shardDetector(key)
+	vm.m.locks[shardID].RLock()
+	entry, ok := vm.m.shards[shardID][key]
+	vm.m.locks[shardID].RUnlock()
+	if ok {
+		vm.m.locks[shardID].countOp(statLoad)
+		return entry.value, ok
+	}
+
+	vm.m.locks[shardID].Lock()
+	defer vm.m.locks[shardID].Unlock()
+	if entry, ok = vm.m.shards[shardID][key]; ok {
+		vm.m.locks[shardID].countOp(statLoad)
+		return entry.value, ok
+	}
+	value := generator()
+	vm.store(shardID, key, value)
+	return value, false
+}
+
+// Delete deletes the value for a key.
+func (vm Versioned[K, V]) Delete(key K) {
+	vm.m.Delete(key)
+}
+
+// DeleteIfVersion deletes the value for a key, if and only if current version of the key equals expectedVersion.
+// Returns true if value was deleted.
+func (vm Versioned[K, V]) DeleteIfVersion(key K, expectedVersion uint64) bool {
+	shardID := vm.m.shardDetector(key)
+	vm.m.locks[shardID].Lock()
+	defer vm.m.locks[shardID].Unlock()
+	entry, ok := vm.m.shards[shardID][key]
+	if !ok || entry.version != expectedVersion {
+		vm.m.locks[shardID].countOp(statLoad)
+		return false
+	}
+	vm.m.locks[shardID].countOp(statDelete)
+	delete(vm.m.shards[shardID], key)
+	vm.m.removed(shardID, key)
+	vm.m.deleted(shardID, 1)
+	return true
+}
+
+// Range calls cb sequentially for each key and value present in the map.
+// If cb returns false, range stops the iteration. See Generic.Range for details.
+func (vm Versioned[K, V]) Range(cb func(K, V) bool) {
+	vm.m.Range(func(key K, entry versionedValue[V]) bool {
+		return cb(key, entry.value)
+	})
+}
+
+// RangeVersioned calls cb sequentially for each key, value and version present in the map.
+// If cb returns false, range stops the iteration. See Generic.Range for details.
+func (vm Versioned[K, V]) RangeVersioned(cb func(K, V, uint64) bool) {
+	vm.m.Range(func(key K, entry versionedValue[V]) bool {
+		return cb(key, entry.value, entry.version)
+	})
+}
+
+// Stats returns map statistics, see Generic.Stats.
+func (vm Versioned[K, V]) Stats() Stats {
+	return vm.m.Stats()
+}
+
+// store sets value with the next shard version and counts store, should be called only under shard write lock.
+func (vm Versioned[K, V]) store(shardID int, key K, value V) uint64 {
+	vm.m.locks[shardID].countOp(statStore)
+	vm.versions[shardID]++
+	version := vm.versions[shardID]
+	vm.m.storing(shardID, key)
+	vm.m.shards[shardID][key] = versionedValue[V]{value: value, version: version}
+	return version
+}
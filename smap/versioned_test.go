@@ -0,0 +1,108 @@
+package smap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lispad/go-generics-tools/smap/shard"
+)
+
+func TestVersioned_StoreIfVersion(t *testing.T) {
+	m := NewVersioned[int, []string](8, 128, shard.Must(shard.Modulo[int](8)))
+	val, version, ok := m.LoadVersioned(1)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(0), version)
+	assert.Nil(t, val)
+
+	version, ok = m.StoreIfVersion(1, []string{"a"}, 5) // key is missing, only zero version matches
+	assert.False(t, ok)
+	assert.Equal(t, uint64(0), version)
+
+	first, ok := m.StoreIfVersion(1, []string{"a"}, 0)
+	assert.True(t, ok)
+	assert.NotZero(t, first)
+
+	version, ok = m.StoreIfVersion(1, []string{"b"}, 0) // key exists
+	assert.False(t, ok)
+	assert.Equal(t, first, version)
+
+	second, ok := m.StoreIfVersion(1, []string{"a", "b"}, first)
+	assert.True(t, ok)
+	assert.Greater(t, second, first)
+
+	val, version, ok = m.LoadVersioned(1)
+	assert.True(t, ok)
+	assert.Equal(t, second, version)
+	assert.Equal(t, []string{"a", "b"}, val)
+
+	_, ok = m.StoreIfVersion(1, []string{"stale"}, first)
+	assert.False(t, ok)
+}
+
+func TestVersioned_DeleteIfVersion(t *testing.T) {
+	m := NewVersioned[int, []string](8, 128, shard.Must(shard.Modulo[int](8)))
+	first := m.StoreVersioned(1, []string{"a"})
+	second := m.StoreVersioned(1, []string{"b"})
+	assert.Greater(t, second, first)
+
+	assert.False(t, m.DeleteIfVersion(1, first))
+	assert.False(t, m.DeleteIfVersion(2, 0))
+	assert.True(t, m.DeleteIfVersion(1, second))
+	_, ok := m.Load(1)
+	assert.False(t, ok)
+
+	third := m.StoreVersioned(1, []string{"c"}) // version is not reused after deletion
+	assert.Greater(t, third, second)
+
+	versions := make(map[int]uint64)
+	m.RangeVersioned(func(k int, v []string, version uint64) bool {
+		versions[k] = version
+		return true
+	})
+	assert.Equal(t, map[int]uint64{1: third}, versions)
+}
+
+func TestVersioned_ConcurrentIncrements(t *testing.T) {
+	m := NewVersioned[string, []int](8, 128, shard.Must(shard.FNV(8)))
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				for { // optimistic update loop
+					val, version, _ := m.LoadVersioned("key")
+					updated := append(append([]int(nil), val...), g)
+					if _, ok := m.StoreIfVersion("key", updated, version); ok {
+						break
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	val, _ := m.Load("key")
+	assert.Len(t, val, 800) // no updates are lost
+}
+
+func TestVersioned_Stats(t *testing.T) {
+	m := NewVersioned[int, string](4, 0, shard.Must(shard.Modulo[int](4)), WithStats())
+	v1 := m.StoreVersioned(1, "a")
+	_, ok := m.StoreIfVersion(1, "b", v1+100) // version mismatch is a load
+	assert.False(t, ok)
+	v2, ok := m.StoreIfVersion(1, "b", v1)
+	assert.True(t, ok)
+	m.LoadOrCreate(1, func() string { return "c" })
+	m.LoadOrCreate(2, func() string { return "c" })
+	assert.False(t, m.DeleteIfVersion(1, v1))
+	assert.True(t, m.DeleteIfVersion(1, v2))
+	m.LoadVersioned(2)
+
+	s := m.Stats()
+	assert.Equal(t, int64(3), s.Stores)
+	assert.Equal(t, int64(4), s.Loads)
+	assert.Equal(t, int64(1), s.Deletes)
+	assert.Equal(t, 1, s.Len())
+}
@@ -0,0 +1,126 @@
+//go:build go1.24
+
+package smap
+
+import (
+	"context"
+	"runtime"
+	"weak"
+)
+
+// Weak is sharded map with weakly referenced values, useful for canonicalisation caches:
+// map does not keep values alive, and entry is removed after the value is collected by GC.
+// Values are stored as pointers, and should not be nil.
+//
+// Entries are removed by cleanups, registered with runtime.AddCleanup, but cleanup is not guaranteed to run:
+// e.g. tiny pointer-free values (less than 16 bytes) are batched by allocator, and cleanup runs only when
+// all values of the batch are collected, or never, if program exits before. So Load, Range and Len also delete
+// entries of collected values they meet.
+type Weak[K comparable, V any] struct {
+	m Generic[K, weak.Pointer[V]]
+}
+
+// NewWeak creates weak-valued sharded map. shardDetector should be idempotent function.
+func NewWeak[K comparable, V any](shardsCount, defaultSize int, shardDetector func(key K) int, opts ...Option) Weak[K, V] {
+	return Weak[K, V]{
+		m: NewGeneric[K, weak.Pointer[V]](shardsCount, defaultSize, shardDetector, opts...),
+	}
+}
+
+// Load returns strong pointer to the value stored in the map for a key, or nil if no value is present,
+// or it's already collected. The ok result indicates whether value was found in the map.
+func (w Weak[K, V]) Load(key K) (*V, bool) {
+	wp, ok := w.m.Load(key)
+	if !ok {
+		return nil, false
+	}
+	value := wp.Value()
+	if value == nil {
+		w.sweep(key, wp)
+		return nil, false
+	}
+	return value, true
+}
+
+// Store sets the value for a key. Entry is removed after value is collected, unless it's replaced before.
+func (w Weak[K, V]) Store(key K, value *V) {
+	wp := weak.Make(value)
+	w.m.Store(key, wp)
+	w.removeOnCleanup(key, value, wp)
+}
+
+// LoadOrCreate returns strong pointer to the existing value for the key if present and not collected yet.
+// Otherwise, it calls generator func, stores and returns the generator's result.
+// Generator is called under shard write lock, so it should not call methods of the map for keys from the same shard.
+// The loaded result is true if the value was loaded, false if stored.
+func (w Weak[K, V]) LoadOrCreate(key K, generator func() *V) (*V, bool) {
+	if value, ok := w.Load(key); ok {
+		return value, true
+	}
+
+	var (
+		value  *V
+		loaded bool
+	)
+	w.m.WithValue(key, func(wp *weak.Pointer[V], exists bool) bool {
+		if exists {
+			value = wp.Value()
+		}
+		if value != nil {
+			loaded = true
+			return true
+		}
+		value = generator()
+		*wp = weak.Make(value)
+		w.removeOnCleanup(key, value, *wp)
+		return true
+	})
+	return value, loaded
+}
+
+// Delete deletes the value for a key.
+func (w Weak[K, V]) Delete(key K) {
+	w.m.Delete(key)
+}
+
+// Range calls cb sequentially for each key and alive value present in the map.
+// If cb returns false, range stops the iteration. See Generic.Range for consistency guarantees.
+func (w Weak[K, V]) Range(cb func(K, *V) bool) {
+	w.m.Range(func(key K, wp weak.Pointer[V]) bool {
+		if value := wp.Value(); value != nil {
+			return cb(key, value)
+		}
+		w.sweep(key, wp)
+		return true
+	})
+}
+
+// Len deletes entries of collected values, and returns count of remaining entries.
+// It locks each shard for write, and values could be collected right after, so result is approximate.
+func (w Weak[K, V]) Len() int {
+	count := 0
+	// single worker, so count is not shared
+	_, _ = w.m.DeleteIf(context.Background(), 1, func(_ K, wp weak.Pointer[V]) bool {
+		if wp.Value() == nil {
+			return true
+		}
+		count++
+		return false
+	})
+	return count
+}
+
+// removeOnCleanup deletes entry after value is collected, if the key still refers to the same weak pointer.
+// Cleanup should not reference value, so it gets only key and weak pointer.
+func (w Weak[K, V]) removeOnCleanup(key K, value *V, wp weak.Pointer[V]) {
+	runtime.AddCleanup(value, func(wp weak.Pointer[V]) {
+		w.sweep(key, wp)
+	}, wp)
+}
+
+// sweep deletes entry of collected value, if the key still refers to the same weak pointer.
+func (w Weak[K, V]) sweep(key K, wp weak.Pointer[V]) {
+	w.m.WithValue(key, func(current *weak.Pointer[V], exists bool) bool {
+		return exists && *current != wp
+	})
+}
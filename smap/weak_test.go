@@ -0,0 +1,150 @@
+//go:build go1.24
+
+package smap
+
+import (
+	"runtime"
+	"testing"
+	"time"
+	"weak"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type weakTestValue struct {
+	name    string
+	payload [1024]byte
+}
+
+func newWeakTestMap() Weak[int, weakTestValue] {
+	return NewWeak[int, weakTestValue](8, 0, func(key int) int {
+		return key % 8
+	})
+}
+
+func TestWeak_LoadStore(t *testing.T) {
+	m := newWeakTestMap()
+	_, ok := m.Load(1)
+	assert.False(t, ok)
+
+	value := &weakTestValue{name: "one"}
+	m.Store(1, value)
+	loaded, ok := m.Load(1)
+	assert.True(t, ok)
+	assert.Same(t, value, loaded)
+
+	m.Delete(1)
+	_, ok = m.Load(1)
+	assert.False(t, ok)
+	runtime.KeepAlive(value)
+}
+
+func TestWeak_LoadOrCreate(t *testing.T) {
+	m := newWeakTestMap()
+	created, loaded := m.LoadOrCreate(1, func() *weakTestValue {
+		return &weakTestValue{name: "one"}
+	})
+	assert.False(t, loaded)
+	assert.Equal(t, "one", created.name)
+
+	again, loaded := m.LoadOrCreate(1, func() *weakTestValue {
+		t.Error("generator should not be called for alive value")
+		return nil
+	})
+	assert.True(t, loaded)
+	assert.Same(t, created, again)
+	runtime.KeepAlive(created)
+}
+
+func TestWeak_RemovesCollectedValues(t *testing.T) {
+	m := newWeakTestMap()
+	alive := &weakTestValue{name: "alive"}
+	m.Store(0, alive)
+	for i := 1; i < 100; i++ {
+		m.Store(i, &weakTestValue{name: "garbage"})
+	}
+
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		count := 0
+		m.m.Range(func(int, weak.Pointer[weakTestValue]) bool {
+			count++
+			return true
+		})
+		return count == 1
+	}, 5*time.Second, 10*time.Millisecond, "entries of collected values should be removed")
+
+	value, ok := m.Load(0)
+	assert.True(t, ok)
+	assert.Same(t, alive, value)
+
+	// collected value is recreated by LoadOrCreate
+	created, loaded := m.LoadOrCreate(1, func() *weakTestValue {
+		return &weakTestValue{name: "recreated"}
+	})
+	assert.False(t, loaded)
+	assert.Equal(t, "recreated", created.name)
+	runtime.KeepAlive(alive)
+	runtime.KeepAlive(created)
+}
+
+func TestWeak_CleanupKeepsReplacedEntry(t *testing.T) {
+	m := newWeakTestMap()
+	m.Store(1, &weakTestValue{name: "old"})
+	replacement := &weakTestValue{name: "new"}
+	m.Store(1, replacement)
+
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	value, ok := m.Load(1)
+	assert.True(t, ok)
+	assert.Same(t, replacement, value)
+
+	count := 0
+	m.Range(func(k int, v *weakTestValue) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 1, count)
+	runtime.KeepAlive(replacement)
+}
+
+func TestWeak_SweepsWithoutCleanup(t *testing.T) {
+	m := newWeakTestMap()
+	alive := &weakTestValue{name: "alive"}
+	m.Store(0, alive)
+	// entries are stored directly, so cleanups are not registered, like for batched tiny values
+	for i := 1; i < 10; i++ {
+		m.m.Store(i, weak.Make(&weakTestValue{name: "garbage"}))
+	}
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		wp, _ := m.m.Load(1)
+		return wp.Value() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	_, ok := m.Load(1)
+	assert.False(t, ok)
+	_, ok = m.m.Load(1)
+	assert.False(t, ok, "Load should delete entry of collected value")
+
+	count := 0
+	m.Range(func(k int, v *weakTestValue) bool {
+		count++
+		return true
+	})
+	assert.Equal(t, 1, count)
+	_, ok = m.m.Load(2)
+	assert.False(t, ok, "Range should delete entries of collected values")
+
+	m.m.Store(1, weak.Make(&weakTestValue{name: "garbage"}))
+	assert.Eventually(t, func() bool {
+		runtime.GC()
+		return m.Len() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	_, ok = m.m.Load(1)
+	assert.False(t, ok, "Len should delete entries of collected values")
+	runtime.KeepAlive(alive)
+}